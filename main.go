@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/Mdromi/golang-search-engine/search-engine/analyzer"
 	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
 	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/pipeline"
 	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
@@ -22,12 +33,31 @@ TASK: 1.Fixing main.go code 2.Create Docs 3.More Testing 4.Implement Advanced Fe
 */
 
 type Config struct {
-	MaxDepth         int    `yaml:"maxDepth"`
-	Concurrency      int    `yaml:"concurrency"`
-	BoltDBPath       string `yaml:"boltDBPath"`
-	RedisAddress     string `yaml:"redisAddress"`
-	FilterDomain     string `yaml:"filterDomain"`
-	ExampleQueryLink string `yaml:"exampleQueryLink"`
+	MaxDepth         int                         `yaml:"maxDepth"`
+	Concurrency      int                         `yaml:"concurrency"`
+	StorageEngine    string                      `yaml:"storageEngine"`
+	BoltDBPath       string                      `yaml:"boltDBPath"`
+	LevelDBPath      string                      `yaml:"levelDBPath"`
+	RedisAddress     string                      `yaml:"redisAddress"`
+	FilterDomain     string                      `yaml:"filterDomain"`
+	ExampleQueryLink string                      `yaml:"exampleQueryLink"`
+	IncludeGlobs     []string                    `yaml:"includeGlobs"`
+	ExcludeGlobs     []string                    `yaml:"excludeGlobs"`
+	Feeds            []string                    `yaml:"feeds"`
+	FeedPollInterval time.Duration               `yaml:"feedPollInterval"`
+	HTTP             crawler.HTTPConfig          `yaml:"http"`
+	ProgressInterval time.Duration               `yaml:"progressInterval"`
+	CrawlReportPath  string                      `yaml:"crawlReportPath"`
+	Traps            crawler.TrapConfig          `yaml:"traps"`
+	Distributed      crawler.RedisFrontierConfig `yaml:"distributed"`
+	Extraction       []crawler.ExtractionProfile `yaml:"extraction"`
+	DocStore         indexer.DocStoreConfig      `yaml:"docStore"`
+	CleanupInterval  time.Duration               `yaml:"cleanupInterval"`
+	Merge            indexer.MergePolicy         `yaml:"merge"`
+	MergeInterval    time.Duration               `yaml:"mergeInterval"`
+	Ingest           indexer.WriterConfig        `yaml:"ingest"`
+	Cache            indexer.CacheConfig         `yaml:"cache"`
+	Snapshots        indexer.SnapshotConfig      `yaml:"snapshots"`
 }
 
 func readConfig() (*Config, error) {
@@ -36,7 +66,17 @@ func readConfig() (*Config, error) {
 		return nil, err
 	}
 
-	var config Config
+	// Fields missing from the file keep their defaults
+	config := Config{
+		StorageEngine: storage.EngineBolt,
+		LevelDBPath:   "data/leveldb",
+		Traps:         crawler.DefaultTrapConfig(),
+		DocStore:      indexer.DefaultDocStoreConfig(),
+		Merge:         indexer.DefaultMergePolicy(),
+		Ingest:        indexer.DefaultWriterConfig(),
+		Cache:         indexer.DefaultCacheConfig(),
+		Snapshots:     indexer.DefaultSnapshotConfig(),
+	}
 	err = yaml.Unmarshal(configFile, &config)
 	if err != nil {
 		return nil, err
@@ -45,6 +85,692 @@ func readConfig() (*Config, error) {
 	return &config, nil
 }
 
+// storePath returns the path of the index of the configured storage engine.
+func storePath(config *Config) string {
+	if config.StorageEngine == storage.EngineLevelDB {
+		return config.LevelDBPath
+	}
+	return config.BoltDBPath
+}
+
+// newCrawler creates a crawler configured from config.yaml.
+func newCrawler(config *Config, log *logrus.Logger) (*crawler.Crawler, error) {
+	c := crawler.NewCrawler(config.MaxDepth, config.Concurrency)
+	c.SetFilterDomain(config.FilterDomain)
+	c.SetFileFilters(config.IncludeGlobs, config.ExcludeGlobs)
+	c.SetTrapConfig(config.Traps)
+
+	if err := c.SetHTTPConfig(config.HTTP); err != nil {
+		return nil, fmt.Errorf("failed to configure HTTP client: %w", err)
+	}
+	if config.FilterDomain == "" && config.HTTP.HasSecrets() {
+		log.Warn("No filterDomain is set, so the job cookies, credentials and headers are only sent to the hosts listed under http.hosts")
+	}
+	if err := c.SetExtractionProfiles(config.Extraction); err != nil {
+		return nil, fmt.Errorf("failed to configure extraction profiles: %w", err)
+	}
+
+	return c, nil
+}
+
+// newCacheRedisClient connects to Redis if the configured cache type needs it, and returns nil otherwise.
+func newCacheRedisClient(config *Config) *redis.Client {
+	if config.Cache.Type != indexer.CacheRedis && config.Cache.Type != indexer.CacheTiered {
+		return nil
+	}
+	return redis.NewClient(&redis.Options{
+		Addr: config.RedisAddress,
+	})
+}
+
+// newIndexer creates an indexer configured from config.yaml. Its query cache
+// uses redisClient if the cache type needs Redis.
+func newIndexer(db storage.Store, redisClient *redis.Client, config *Config) (*indexer.Indexer, error) {
+	cache, err := indexer.NewCache(config.Cache, redisClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up cache: %w", err)
+	}
+
+	idx := indexer.NewIndexer(db, cache)
+	idx.SetDocStoreConfig(config.DocStore)
+	idx.SetMergePolicy(config.Merge)
+	idx.SetCacheConfig(config.Cache)
+	return idx, nil
+}
+
+// newPipeline creates a pipeline ingesting through a batched writer configured from config.yaml.
+// The write-ahead log, if configured, is kept next to the index and replayed first.
+func newPipeline(idx *indexer.Indexer, config *Config) (*pipeline.Pipeline, func() error, error) {
+	writerConfig := config.Ingest
+	if writerConfig.WALPath != "" {
+		projectRoot, err := indexer.FindProjectRoot()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find project root: %w", err)
+		}
+		writerConfig.WALPath = filepath.Join(projectRoot, writerConfig.WALPath)
+	}
+
+	writer, err := indexer.NewWriter(idx, writerConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pipeline.NewPipelineWithWriter(writer), writer.Close, nil
+}
+
+// runCommand dispatches a command-line subcommand.
+func runCommand(name string, args []string, config *Config, log *logrus.Logger) error {
+	switch name {
+	case "ingest":
+		return runIngest(args, config, log)
+	case "feeds":
+		return runFeeds(config, log)
+	case "worker":
+		return runWorker(args, config, log)
+	case "index":
+		return runIndex(args, config, log)
+	case "collection":
+		return runCollection(args, config, log)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// runIngest indexes WARC files and directories of HTML files without network access.
+func runIngest(args []string, config *Config, log *logrus.Logger) error {
+	flags := flag.NewFlagSet("ingest", flag.ContinueOnError)
+	baseURL := flags.String("base-url", "", "base URL for pages read from HTML directories (defaults to file:// URLs)")
+	collection := flags.String("collection", indexer.DefaultCollection, "collection to index the pages into")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("usage: ingest [-base-url URL] [-collection NAME] <file.warc[.gz] | directory>...")
+	}
+
+	// Set up the index store
+	db, cleanup, err := indexer.NewStore(config.StorageEngine, storePath(config))
+	if err != nil {
+		return fmt.Errorf("failed to set up index store: %w", err)
+	}
+	defer cleanup()
+
+	store, setCollection, err := useCollection(db, *collection)
+	if err != nil {
+		return err
+	}
+
+	// Every collection logs its pending documents separately
+	if *collection != indexer.DefaultCollection && config.Ingest.WALPath != "" {
+		collectionConfig := *config
+		collectionConfig.Ingest.WALPath += "." + *collection
+		config = &collectionConfig
+	}
+
+	// Offline ingestion never queries, so it needs no cache
+	idx := indexer.NewIndexer(store, nil)
+	setCollection(idx)
+	p, closeWriter, err := newPipeline(idx, config)
+	if err != nil {
+		return err
+	}
+	defer closeWriter()
+
+	// Apply the extraction profiles to the archived pages as the live crawler would
+	var extraction crawler.Hook
+	if len(config.Extraction) > 0 {
+		extraction, err = crawler.NewExtractionHook(config.Extraction)
+		if err != nil {
+			return fmt.Errorf("failed to configure extraction profiles: %w", err)
+		}
+	}
+
+	// Pages that do not match the schema of the collection are skipped
+	skipped := 0
+	add := func(doc *crawler.Document) error {
+		if extraction != nil {
+			if err := extraction.AfterParse(doc); err != nil {
+				return err
+			}
+		}
+		err := p.Add(doc)
+		if errors.Is(err, indexer.ErrInvalidDocument) {
+			log.Warn("Skipping page: ", err)
+			skipped++
+			return nil
+		}
+		return err
+	}
+
+	for _, path := range flags.Args() {
+		log.Info("Ingesting ", path)
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			err = crawler.ReadHTMLDir(path, *baseURL, add)
+		} else {
+			err = ingestWARC(path, add)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to ingest %s: %w", path, err)
+		}
+	}
+
+	if skipped > 0 {
+		log.Warn("Skipped ", skipped, " pages not matching the schema of collection ", *collection)
+	}
+
+	log.Info("Indexing data...")
+	if err := p.Flush(); err != nil {
+		return fmt.Errorf("failed to index data: %w", err)
+	}
+	log.Info("Indexing finished.")
+
+	return nil
+}
+
+// runIndex runs a maintenance command on a collection of an index file, by default the configured one.
+func runIndex(args []string, config *Config, log *logrus.Logger) error {
+	indexFlags := flag.NewFlagSet("index", flag.ContinueOnError)
+	collection := indexFlags.String("collection", indexer.DefaultCollection, "collection to run the command on")
+	if err := indexFlags.Parse(args); err != nil {
+		return err
+	}
+	args = indexFlags.Args()
+	if len(args) == 0 {
+		return fmt.Errorf("usage: index [-collection NAME] migrate|cleanup|merge|stats|fsck|snapshot|restore [path]")
+	}
+
+	var run func(idx *indexer.Indexer, path string, log *logrus.Logger) error
+	rest := args[1:]
+	switch args[0] {
+	case "migrate":
+		run = runMigrate
+	case "cleanup":
+		run = runCleanup
+	case "merge":
+		run = runMerge
+	case "stats":
+		run = runStats
+	case "fsck":
+		flags := flag.NewFlagSet("index fsck", flag.ContinueOnError)
+		repair := flags.Bool("repair", false, "rebuild the structures derived from the document store")
+		if err := flags.Parse(rest); err != nil {
+			return err
+		}
+		rest = flags.Args()
+		run = func(idx *indexer.Indexer, path string, log *logrus.Logger) error {
+			return runFsck(idx, path, *repair, log)
+		}
+	case "snapshot":
+		flags := flag.NewFlagSet("index snapshot", flag.ContinueOnError)
+		base := flags.String("base", "", "earlier snapshot to write an incremental snapshot against")
+		if err := flags.Parse(rest); err != nil {
+			return err
+		}
+		if flags.NArg() == 0 {
+			return fmt.Errorf("usage: index snapshot [-base DIR] DIR [path]")
+		}
+		dir := flags.Arg(0)
+		rest = flags.Args()[1:]
+		run = func(idx *indexer.Indexer, path string, log *logrus.Logger) error {
+			return runSnapshot(idx, path, dir, *base, log)
+		}
+	case "restore":
+		if len(rest) == 0 {
+			return fmt.Errorf("usage: index restore DIR [path]")
+		}
+		dir := rest[0]
+		rest = rest[1:]
+		run = func(idx *indexer.Indexer, path string, log *logrus.Logger) error {
+			return runRestore(idx, path, dir, log)
+		}
+	default:
+		return fmt.Errorf("unknown index command %q", args[0])
+	}
+
+	path := storePath(config)
+	if len(rest) > 0 {
+		path = rest[0]
+	}
+
+	db, cleanup, err := indexer.NewStore(config.StorageEngine, path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer cleanup()
+
+	store, setCollection, err := useCollection(db, *collection)
+	if err != nil {
+		return err
+	}
+
+	idx := indexer.NewIndexer(store, nil)
+	idx.SetDocStoreConfig(config.DocStore)
+	idx.SetMergePolicy(config.Merge)
+	setCollection(idx)
+	return run(idx, path, log)
+}
+
+// useCollection returns the store of a collection in db and a function configuring an
+// indexer for it. The default collection is db itself, configured by config.yaml alone.
+func useCollection(db storage.Store, name string) (storage.Store, func(*indexer.Indexer), error) {
+	if name == indexer.DefaultCollection {
+		return db, func(*indexer.Indexer) {}, nil
+	}
+
+	collections := indexer.NewCollections(db)
+	collection, err := collections.Get(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return collections.Store(collection), func(idx *indexer.Indexer) {
+		idx.SetCollection(collection)
+	}, nil
+}
+
+// runCollection creates, drops or lists the collections of the configured index.
+func runCollection(args []string, config *Config, log *logrus.Logger) error {
+	usage := fmt.Errorf("usage: collection create [-schema FILE] [-stop-words WORDS] [-min-length N] [-max-length N] [-keep-case] NAME | drop NAME | list")
+	if len(args) == 0 {
+		return usage
+	}
+
+	var run func(collections *indexer.Collections) error
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("collection create", flag.ContinueOnError)
+		stopWords := flags.String("stop-words", "", "comma-separated terms left out of the index")
+		minLength := flags.Int("min-length", 0, "length below which terms are left out")
+		maxLength := flags.Int("max-length", 0, "length above which terms are left out; 0 for no limit")
+		keepCase := flags.Bool("keep-case", false, "keep the case of terms")
+		schemaPath := flags.String("schema", "", "YAML file declaring the typed fields of the documents")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if flags.NArg() != 1 {
+			return usage
+		}
+
+		var schema indexer.Schema
+		if *schemaPath != "" {
+			data, err := ioutil.ReadFile(*schemaPath)
+			if err != nil {
+				return err
+			}
+			if err := yaml.UnmarshalStrict(data, &schema); err != nil {
+				return fmt.Errorf("failed to read schema %s: %w", *schemaPath, err)
+			}
+		}
+
+		collection := indexer.CollectionConfig{
+			Name: flags.Arg(0),
+			Analyzer: analyzer.Config{
+				KeepCase:  *keepCase,
+				MinLength: *minLength,
+				MaxLength: *maxLength,
+			},
+			DocStore: config.DocStore,
+			Schema:   schema,
+		}
+		if *stopWords != "" {
+			collection.Analyzer.StopWords = strings.Split(*stopWords, ",")
+		}
+		run = func(collections *indexer.Collections) error {
+			if _, err := collections.Create(collection); err != nil {
+				return fmt.Errorf("failed to create collection: %w", err)
+			}
+			log.Info("Created collection ", collection.Name)
+			return nil
+		}
+	case "drop":
+		if len(args) != 2 {
+			return usage
+		}
+		run = func(collections *indexer.Collections) error {
+			if err := collections.Drop(args[1]); err != nil {
+				return fmt.Errorf("failed to drop collection: %w", err)
+			}
+			log.Info("Dropped collection ", args[1])
+			return nil
+		}
+	case "list":
+		run = func(collections *indexer.Collections) error {
+			list, err := collections.List()
+			if err != nil {
+				return err
+			}
+			fmt.Println(indexer.DefaultCollection)
+			for _, collection := range list {
+				fmt.Println(collection.Name)
+			}
+			return nil
+		}
+	default:
+		return fmt.Errorf("unknown collection command %q", args[0])
+	}
+
+	db, cleanup, err := indexer.NewStore(config.StorageEngine, storePath(config))
+	if err != nil {
+		return fmt.Errorf("failed to set up index store: %w", err)
+	}
+	defer cleanup()
+
+	return run(indexer.NewCollections(db))
+}
+
+// runMigrate rewrites an index file into the current format.
+func runMigrate(idx *indexer.Indexer, path string, log *logrus.Logger) error {
+	log.Info("Migrating ", path)
+	report, err := idx.Migrate()
+	if err != nil {
+		return fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+	for _, key := range report.Skipped {
+		log.Warn("Skipped unreadable legacy entry ", key)
+	}
+	log.WithFields(logrus.Fields{
+		"terms":     report.Terms,
+		"documents": report.Documents,
+		"skipped":   len(report.Skipped),
+	}).Info("Migration finished.")
+
+	return nil
+}
+
+// runCleanup removes the postings of deleted documents from an index file.
+func runCleanup(idx *indexer.Indexer, path string, log *logrus.Logger) error {
+	removed, err := idx.Cleanup()
+	if err != nil {
+		return fmt.Errorf("failed to clean up %s: %w", path, err)
+	}
+	log.WithField("postings", removed).Info("Cleanup finished.")
+
+	return nil
+}
+
+// runMerge merges all segments of an index file into one.
+func runMerge(idx *indexer.Indexer, path string, log *logrus.Logger) error {
+	if err := idx.ForceMerge(); err != nil {
+		return fmt.Errorf("failed to merge %s: %w", path, err)
+	}
+	return runStats(idx, path, log)
+}
+
+// runFsck checks an index file for inconsistencies, repairing them if asked to.
+func runFsck(idx *indexer.Indexer, path string, repair bool, log *logrus.Logger) error {
+	log.Info("Checking ", path)
+	report, err := idx.Fsck(repair)
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %w", path, err)
+	}
+	for _, issue := range report.Issues {
+		entry := log.WithFields(logrus.Fields{"kind": issue.Kind, "repaired": issue.Repaired, "dropped": issue.Dropped})
+		if issue.Repaired {
+			entry.Info(issue.Detail)
+		} else {
+			entry.Warn(issue.Detail)
+		}
+	}
+	log.WithFields(logrus.Fields{
+		"segments":  report.Segments,
+		"terms":     report.Terms,
+		"postings":  report.Postings,
+		"documents": report.Documents,
+		"issues":    len(report.Issues),
+	}).Info("Check finished.")
+
+	if unrepaired := report.Unrepaired(); unrepaired > 0 {
+		return fmt.Errorf("%s has %d unrepaired issues", path, unrepaired)
+	}
+	return nil
+}
+
+// runSnapshot writes a point-in-time snapshot of an index, incremental if a base snapshot is given.
+func runSnapshot(idx *indexer.Indexer, path, dir, base string, log *logrus.Logger) error {
+	log.Info("Writing snapshot of ", path, " to ", dir)
+	manifest, err := idx.Snapshot(dir, base)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot %s: %w", path, err)
+	}
+
+	written := 0
+	for _, chunk := range manifest.Chunks {
+		if !chunk.Base {
+			written++
+		}
+	}
+	log.WithFields(logrus.Fields{
+		"entries": manifest.Entries,
+		"bytes":   manifest.Bytes,
+		"chunks":  len(manifest.Chunks),
+		"written": written,
+	}).Info("Snapshot finished.")
+
+	return nil
+}
+
+// runRestore restores a snapshot into an empty index.
+func runRestore(idx *indexer.Indexer, path, dir string, log *logrus.Logger) error {
+	log.Info("Restoring snapshot ", dir, " into ", path)
+	manifest, err := idx.Restore(dir)
+	if err != nil {
+		return fmt.Errorf("failed to restore %s: %w", dir, err)
+	}
+	log.WithFields(logrus.Fields{
+		"entries": manifest.Entries,
+		"created": manifest.Created,
+	}).Info("Restore finished.")
+
+	return nil
+}
+
+// runStats logs the segments of an index file.
+func runStats(idx *indexer.Indexer, path string, log *logrus.Logger) error {
+	stats, err := idx.SegmentStats()
+	if err != nil {
+		return fmt.Errorf("failed to read segments of %s: %w", path, err)
+	}
+	for _, segment := range stats.Segments {
+		log.WithFields(logrus.Fields{
+			"segment":  segment.ID,
+			"terms":    segment.Terms,
+			"postings": segment.Postings,
+			"bytes":    segment.Bytes,
+		}).Info("Segment")
+	}
+	log.WithFields(logrus.Fields{
+		"segments": len(stats.Segments),
+		"postings": stats.Postings,
+		"bytes":    stats.Bytes,
+	}).Info("Index ", path)
+
+	return nil
+}
+
+// runFeeds polls the configured feeds on a schedule and indexes their new entries until interrupted.
+func runFeeds(config *Config, log *logrus.Logger) error {
+	if len(config.Feeds) == 0 {
+		return fmt.Errorf("no feeds configured")
+	}
+
+	// Set up the index store
+	db, cleanup, err := indexer.NewStore(config.StorageEngine, storePath(config))
+	if err != nil {
+		return fmt.Errorf("failed to set up index store: %w", err)
+	}
+	defer cleanup()
+
+	// Set up Redis if the cache uses it
+	redisClient := newCacheRedisClient(config)
+	if redisClient != nil {
+		defer redisClient.Close()
+	}
+
+	idx, err := newIndexer(db, redisClient, config)
+	if err != nil {
+		return err
+	}
+
+	c, err := newCrawler(config, log)
+	if err != nil {
+		return err
+	}
+	p, closeWriter, err := newPipeline(idx, config)
+	if err != nil {
+		return err
+	}
+	defer closeWriter()
+	c.SetIndex(p)
+	c.AddHook(crawler.HookFuncs{OnErrorFunc: func(url string, err error) {
+		log.Warn("Failed to fetch ", url, ": ", err)
+	}})
+	for _, feed := range config.Feeds {
+		c.AddFeed(feed)
+	}
+
+	interval := config.FeedPollInterval
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Purge the postings of deleted and changed entries in the background
+	if config.CleanupInterval > 0 {
+		go func() {
+			if err := idx.RunCleanup(ctx, config.CleanupInterval); err != nil {
+				log.Error("Index cleanup failed: ", err)
+			}
+		}()
+	}
+
+	// Merge the segments written by every poll in the background
+	if config.MergeInterval > 0 {
+		go func() {
+			if err := idx.RunMerges(ctx, config.MergeInterval); err != nil {
+				log.Error("Index merge failed: ", err)
+			}
+		}()
+	}
+
+	// Snapshot the index while it is written
+	if config.Snapshots.Interval > 0 {
+		snapshots := config.Snapshots
+		projectRoot, err := indexer.FindProjectRoot()
+		if err != nil {
+			return err
+		}
+		snapshots.Dir = filepath.Join(projectRoot, snapshots.Dir)
+		go func() {
+			if err := idx.RunSnapshots(ctx, snapshots); err != nil {
+				log.Error("Index snapshot failed: ", err)
+			}
+		}()
+	}
+
+	log.Info("Polling feeds every ", interval)
+	return c.RunFeeds(ctx, interval, func() error {
+		log.Info("Indexing feed entries...")
+		return p.Flush()
+	})
+}
+
+// runWorker joins a distributed crawl through the shared Redis frontier and indexes
+// the pages this worker fetched once the frontier is exhausted.
+func runWorker(args []string, config *Config, log *logrus.Logger) error {
+	flags := flag.NewFlagSet("worker", flag.ContinueOnError)
+	reset := flags.Bool("reset", false, "forget the URLs, queues and leases of the job before crawling, so it runs again from its seed")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// Set up the index store
+	db, cleanup, err := indexer.NewStore(config.StorageEngine, storePath(config))
+	if err != nil {
+		return fmt.Errorf("failed to set up index store: %w", err)
+	}
+	defer cleanup()
+
+	// Set up Redis
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: config.RedisAddress,
+	})
+	defer redisClient.Close()
+
+	frontierConfig := config.Distributed
+	if frontierConfig.WorkerID == "" {
+		host, _ := os.Hostname()
+		frontierConfig.WorkerID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	frontier := crawler.NewRedisFrontier(redisClient, frontierConfig)
+
+	c, err := newCrawler(config, log)
+	if err != nil {
+		return err
+	}
+	idx, err := newIndexer(db, redisClient, config)
+	if err != nil {
+		return err
+	}
+	p, closeWriter, err := newPipeline(idx, config)
+	if err != nil {
+		return err
+	}
+	defer closeWriter()
+	c.SetIndex(p)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Only one worker may reset the job, before the others start
+	if *reset {
+		if err := frontier.Reset(ctx); err != nil {
+			return err
+		}
+		log.Info("Reset crawl job ", frontierConfig.JobID)
+	}
+
+	// Seeding is idempotent, so every worker may do it
+	if _, err := frontier.Push(ctx, config.ExampleQueryLink, 0); err != nil {
+		return err
+	}
+
+	log.Info("Worker ", frontierConfig.WorkerID, " crawling...")
+	if err := c.CrawlDistributed(ctx, frontier); err != nil {
+		return err
+	}
+	log.Info("Crawling finished.")
+
+	log.Info("Indexing data...")
+	if err := p.Flush(); err != nil {
+		return fmt.Errorf("failed to index data: %w", err)
+	}
+	log.Info("Indexing finished.")
+
+	return nil
+}
+
+// ingestWARC feeds the pages of a single WARC file to fn.
+func ingestWARC(path string, fn func(*crawler.Document) error) error {
+	ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(path, ".gz")))
+	if ext != ".warc" {
+		return fmt.Errorf("unsupported file type %q", filepath.Base(path))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return crawler.ReadWARC(file, fn)
+}
+
 func main() {
 	// Read configuration from config.yaml
 	config, err := readConfig()
@@ -58,6 +784,14 @@ func main() {
 		FullTimestamp: true,
 	})
 
+	// Run a subcommand if one was given
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:], config, log); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Set up BoltDB
 	// db, cleanup, err := indexer.NewBoltDB(config.BoltDBPath)
 	// if err != nil {
@@ -76,30 +810,67 @@ func main() {
 		}
 	}()
 
-	// Set up Redis
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: config.RedisAddress,
-	})
-	defer redisClient.Close()
+	// Set up Redis if the cache uses it
+	redisClient := newCacheRedisClient(config)
+	if redisClient != nil {
+		defer redisClient.Close()
+	}
 
 	// Initialize the indexer
-	idx := indexer.NewIndexer(db.DB, redisClient)
+	idx, err := newIndexer(db.DB, redisClient, config)
+	if err != nil {
+		log.Fatal("Failed to set up indexer:", err)
+	}
 
-	// Set up the crawler
-	c := crawler.NewCrawler(config.MaxDepth, config.Concurrency)
-	c.SetFilterDomain(config.FilterDomain)
+	// Set up the crawler, passing every page to the indexing pipeline
+	c, err := newCrawler(config, log)
+	if err != nil {
+		log.Fatal("Failed to set up crawler:", err)
+	}
+	// The in-memory index does not outlive the process, so it needs no write-ahead log
+	writer, err := indexer.NewWriter(idx, indexer.WriterConfig{BatchSize: config.Ingest.BatchSize})
+	if err != nil {
+		log.Fatal("Failed to set up indexing:", err)
+	}
+	p := pipeline.NewPipelineWithWriter(writer)
+	c.SetIndex(p)
+
+	// Log the crawl progress periodically
+	progressCtx, stopProgress := context.WithCancel(context.Background())
+	if config.ProgressInterval > 0 {
+		go c.LogProgress(progressCtx, log, config.ProgressInterval)
+	}
 
 	// Start crawling from the provided URL with depth 0
 	log.Info("Starting crawling...")
 	c.Crawl(config.ExampleQueryLink, 0)
-	log.Info("Crawling finished.")
 
 	// Wait for crawling to finish
 	c.Wait()
+	stopProgress()
+	log.Info("Crawling finished.")
+
+	// Write the final crawl report
+	if config.CrawlReportPath != "" {
+		if err := c.WriteReport(config.CrawlReportPath); err != nil {
+			log.Warn("Failed to write crawl report:", err)
+		}
+	}
+
+	// Poll the configured feeds once
+	if len(config.Feeds) > 0 {
+		for _, feed := range config.Feeds {
+			c.AddFeed(feed)
+		}
+		log.Info("Polling feeds...")
+		if err := c.PollFeeds(); err != nil {
+			log.Warn(err)
+		}
+	}
 
-	// After crawling, index the collected data
+	// After crawling, index the collected documents
 	log.Info("Indexing data...")
-	if err := idx.Index(c.GetCollectedData()); err != nil {
+	if err := p.Flush(); err != nil {
 		log.Fatal("Failed to index data:", err)
 	}
 	log.Info("Indexing finished.")
@@ -114,7 +885,7 @@ func main() {
 		FilterDomain: config.FilterDomain,
 		SortBy:       "relevance", // or "date"
 	}
-	results, err := s.Search(query, options)
+	results, err := s.SearchDocuments(query, options)
 	if err != nil {
 		log.Fatal("Failed to search:", err)
 	}
@@ -122,8 +893,7 @@ func main() {
 
 	// Print the search results
 	log.Info("Search Results:")
-	fmt.Println("results", results)
-	for i, url := range results {
-		fmt.Printf("%d. %s\n", i+1, url)
+	for i, doc := range results {
+		fmt.Printf("%d. %s\n   %s\n", i+1, doc.Title, doc.URL)
 	}
 }
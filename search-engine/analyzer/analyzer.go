@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Config configures how an Analyzer turns text into terms.
+type Config struct {
+	KeepCase  bool     `yaml:"keepCase" json:"keepCase,omitempty"`   // keep the case of terms instead of lowercasing them
+	StopWords []string `yaml:"stopWords" json:"stopWords,omitempty"` // terms left out of documents and queries
+	MinLength int      `yaml:"minLength" json:"minLength,omitempty"` // shorter terms are left out
+	MaxLength int      `yaml:"maxLength" json:"maxLength,omitempty"` // longer terms are left out; 0 for no limit
+}
+
+// Analyzer splits text into terms according to its config.
+type Analyzer struct {
+	config    Config
+	stopWords map[string]bool
+}
+
+// defaultAnalyzer analyzes text for Analyze.
+var defaultAnalyzer = New(Config{})
+
+// New creates an Analyzer.
+func New(config Config) *Analyzer {
+	stopWords := make(map[string]bool, len(config.StopWords))
+	for _, word := range config.StopWords {
+		if !config.KeepCase {
+			word = strings.ToLower(word)
+		}
+		stopWords[word] = true
+	}
+
+	return &Analyzer{
+		config:    config,
+		stopWords: stopWords,
+	}
+}
+
+// Analyze splits text into lowercase terms suitable for indexing and querying.
+func Analyze(text string) []string {
+	return defaultAnalyzer.Analyze(text)
+}
+
+// Analyze splits text into terms suitable for indexing and querying.
+func (a *Analyzer) Analyze(text string) []string {
+	// Convert the text to lowercase
+	if !a.config.KeepCase {
+		text = strings.ToLower(text)
+	}
+
+	// Split the text on anything that is not a letter or a digit
+	terms := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(a.stopWords) == 0 && a.config.MinLength == 0 && a.config.MaxLength == 0 {
+		return terms
+	}
+
+	// Leave out stop words and terms of unwanted lengths
+	kept := terms[:0]
+	for _, term := range terms {
+		length := utf8.RuneCountInString(term)
+		if a.stopWords[term] || length < a.config.MinLength || (a.config.MaxLength > 0 && length > a.config.MaxLength) {
+			continue
+		}
+		kept = append(kept, term)
+	}
+	return kept
+}
@@ -0,0 +1,60 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"net/url"
+	"strings"
+)
+
+// DecodeLegacyURLs decodes a URL list written by earlier versions of the indexer,
+// either gzip-compressed gob or comma-joined. It reports false if the value is
+// not a list of absolute URLs, e.g. because it holds page text instead.
+func DecodeLegacyURLs(value []byte) ([]string, bool) {
+	if urls, ok := decodeGzipGob(value); ok {
+		return urls, true
+	}
+
+	urls := strings.Split(string(value), ",")
+	for _, u := range urls {
+		if !IsAbsoluteURL(u) {
+			return nil, false
+		}
+	}
+	return urls, true
+}
+
+// IsAbsoluteURL reports whether s is an absolute http, https or file URL.
+func IsAbsoluteURL(s string) bool {
+	if strings.ContainsAny(s, " \t\r\n") {
+		return false
+	}
+	parsed, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+		return parsed.Host != ""
+	case "file":
+		return parsed.Path != ""
+	default:
+		return false
+	}
+}
+
+// decodeGzipGob decodes a gzip-compressed gob of a string slice.
+func decodeGzipGob(value []byte) ([]string, bool) {
+	gzr, err := gzip.NewReader(bytes.NewReader(value))
+	if err != nil {
+		return nil, false
+	}
+	defer gzr.Close()
+
+	var urls []string
+	if err := gob.NewDecoder(gzr).Decode(&urls); err != nil {
+		return nil, false
+	}
+	return urls, true
+}
@@ -0,0 +1,210 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// PostingsVersion is the version of the postings format written by EncodePostings.
+const PostingsVersion = 1
+
+// postingsMagic starts every encoded postings list.
+var postingsMagic = []byte{'P', 'L'}
+
+var (
+	// ErrCorruptPostings is returned when a postings list cannot be decoded.
+	ErrCorruptPostings = errors.New("corrupt postings list")
+
+	// ErrNotPostings is returned when a value does not start with the postings header.
+	ErrNotPostings = errors.New("not a postings list")
+
+	// ErrUnsupportedVersion is returned for postings written by a newer format version.
+	ErrUnsupportedVersion = errors.New("unsupported postings format version")
+)
+
+// Posting records the occurrences of a term in one document.
+type Posting struct {
+	DocID     uint64
+	Freq      uint32   // term frequency
+	Positions []uint32 // token positions of the term, ascending; empty if unknown
+}
+
+// Validate checks that the posting can be encoded.
+func (p Posting) Validate() error {
+	if len(p.Positions) != 0 && len(p.Positions) != int(p.Freq) {
+		return fmt.Errorf("posting of document %d has %d positions for frequency %d", p.DocID, len(p.Positions), p.Freq)
+	}
+	for i := 1; i < len(p.Positions); i++ {
+		if p.Positions[i] < p.Positions[i-1] {
+			return fmt.Errorf("posting of document %d has unsorted positions", p.DocID)
+		}
+	}
+	return nil
+}
+
+// EncodePostings encodes a postings list sorted by ascending, unique document IDs.
+//
+// The format is a header ("PL" and a version byte) followed by varints:
+// the posting count, then for every posting the document ID delta,
+// freq<<1|hasPositions and, if present, the position deltas.
+// DecodePostings(EncodePostings(list)) returns list for every valid list.
+func EncodePostings(postings []Posting) ([]byte, error) {
+	buf := make([]byte, 0, 8+len(postings)*4)
+	buf = append(buf, postingsMagic...)
+	buf = append(buf, PostingsVersion)
+	buf = binary.AppendUvarint(buf, uint64(len(postings)))
+
+	var lastDoc uint64
+	for i, posting := range postings {
+		if err := posting.Validate(); err != nil {
+			return nil, err
+		}
+		if i > 0 && posting.DocID <= lastDoc {
+			return nil, fmt.Errorf("postings are not sorted by unique document ID at %d", posting.DocID)
+		}
+
+		buf = binary.AppendUvarint(buf, posting.DocID-lastDoc)
+		lastDoc = posting.DocID
+
+		flags := uint64(posting.Freq) << 1
+		if len(posting.Positions) > 0 {
+			flags |= 1
+		}
+		buf = binary.AppendUvarint(buf, flags)
+
+		var lastPos uint32
+		for _, pos := range posting.Positions {
+			buf = binary.AppendUvarint(buf, uint64(pos-lastPos))
+			lastPos = pos
+		}
+	}
+
+	return buf, nil
+}
+
+// DecodePostings decodes a complete postings list.
+func DecodePostings(data []byte) ([]Posting, error) {
+	var postings []Posting
+	it := NewPostingsIterator(data)
+	for it.Next() {
+		postings = append(postings, it.Posting())
+	}
+	return postings, it.Err()
+}
+
+// MergePostings merges new postings into a list, replacing the postings of documents present in both.
+func MergePostings(existing, added []Posting) []Posting {
+	replaced := make(map[uint64]bool, len(added))
+	for _, posting := range added {
+		replaced[posting.DocID] = true
+	}
+
+	merged := make([]Posting, 0, len(existing)+len(added))
+	for _, posting := range existing {
+		if !replaced[posting.DocID] {
+			merged = append(merged, posting)
+		}
+	}
+	merged = append(merged, added...)
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].DocID < merged[j].DocID
+	})
+	return merged
+}
+
+// PostingsIterator reads an encoded postings list one posting at a time.
+type PostingsIterator struct {
+	data      []byte
+	remaining uint64
+	posting   Posting
+	err       error
+}
+
+// NewPostingsIterator creates an iterator over an encoded postings list.
+// An empty value yields no postings.
+func NewPostingsIterator(data []byte) *PostingsIterator {
+	it := &PostingsIterator{data: data}
+	if len(data) == 0 {
+		return it
+	}
+
+	// Check the header
+	switch {
+	case len(data) < len(postingsMagic)+1 || string(data[:len(postingsMagic)]) != string(postingsMagic):
+		it.err = ErrNotPostings
+		return it
+	case data[len(postingsMagic)] != PostingsVersion:
+		it.err = fmt.Errorf("%w %d", ErrUnsupportedVersion, data[len(postingsMagic)])
+		return it
+	}
+	it.data = data[len(postingsMagic)+1:]
+
+	it.remaining = it.uvarint()
+	return it
+}
+
+// Next advances to the next posting and reports whether there is one.
+func (it *PostingsIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.remaining == 0 {
+		if len(it.data) > 0 {
+			it.err = ErrCorruptPostings
+		}
+		return false
+	}
+	it.remaining--
+
+	it.posting.DocID += it.uvarint()
+
+	flags := it.uvarint()
+	freq := flags >> 1
+	if it.err == nil && (freq > 1<<32-1 || (flags&1 == 1 && freq > uint64(len(it.data)))) {
+		it.err = ErrCorruptPostings
+	}
+	if it.err != nil {
+		return false
+	}
+	it.posting.Freq = uint32(freq)
+
+	it.posting.Positions = nil
+	if flags&1 == 1 {
+		positions := make([]uint32, freq)
+		var pos uint32
+		for i := range positions {
+			pos += uint32(it.uvarint())
+			positions[i] = pos
+		}
+		it.posting.Positions = positions
+	}
+
+	return it.err == nil
+}
+
+// Posting returns the current posting.
+func (it *PostingsIterator) Posting() Posting {
+	return it.posting
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *PostingsIterator) Err() error {
+	return it.err
+}
+
+// uvarint reads the next varint from the list.
+func (it *PostingsIterator) uvarint() uint64 {
+	if it.err != nil {
+		return 0
+	}
+	value, n := binary.Uvarint(it.data)
+	if n <= 0 {
+		it.err = ErrCorruptPostings
+		return 0
+	}
+	it.data = it.data[n:]
+	return value
+}
@@ -0,0 +1,74 @@
+package codec
+
+import "math"
+
+// TriePrecisionStep is the number of low bits each level of a numeric trie drops.
+// Every value is indexed once per level, and a range is covered by at most
+// 2*(2^TriePrecisionStep-1) prefixes per level.
+const TriePrecisionStep = 4
+
+// SortableFloat64 maps a float64 to a uint64 with the same order.
+func SortableFloat64(f float64) uint64 {
+	// Negative zero sorts as zero
+	if f == 0 {
+		f = 0
+	}
+	bits := math.Float64bits(f)
+	if bits>>63 == 1 {
+		return ^bits
+	}
+	return bits | 1<<63
+}
+
+// SortableInt64 maps an int64 to a uint64 with the same order.
+func SortableInt64(n int64) uint64 {
+	return uint64(n) ^ 1<<63
+}
+
+// TriePrefixes calls fn with the prefix of a value at every level of the trie,
+// from the full value at shift 0 to its highest TriePrecisionStep bits.
+func TriePrefixes(value uint64, fn func(shift uint, prefix uint64)) {
+	for shift := uint(0); shift < 64; shift += TriePrecisionStep {
+		fn(shift, value>>shift)
+	}
+}
+
+// SplitRange calls fn with the fewest trie prefixes whose values together are
+// exactly the inclusive range [lo, hi]. It calls fn for nothing if lo > hi.
+func SplitRange(lo, hi uint64, fn func(shift uint, prefix uint64)) {
+	const mask = 1<<TriePrecisionStep - 1
+
+	for shift := uint(0); lo <= hi; shift += TriePrecisionStep {
+		// The top level holds too few prefixes to group further
+		if shift+TriePrecisionStep >= 64 {
+			for prefix := lo; ; prefix++ {
+				fn(shift, prefix)
+				if prefix == hi {
+					return
+				}
+			}
+		}
+
+		// Take the prefixes up to the first full block of the next level
+		for lo&mask != 0 {
+			fn(shift, lo)
+			if lo == hi {
+				return
+			}
+			lo++
+		}
+
+		// and those after the last one
+		for hi&mask != mask {
+			fn(shift, hi)
+			if lo == hi {
+				return
+			}
+			hi--
+		}
+
+		// The full blocks in between are prefixes of the next level
+		lo >>= TriePrecisionStep
+		hi >>= TriePrecisionStep
+	}
+}
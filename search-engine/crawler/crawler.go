@@ -1,13 +1,12 @@
 package crawler
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
+	neturl "net/url"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/PuerkitoBio/goquery"
 )
 
 // Crawler is a web crawler that fetches and collects data from web pages.
@@ -17,14 +16,32 @@ type Crawler struct {
 	concurrency   int
 	rateLimiter   <-chan time.Time
 	filterDomain  string
+	includeGlobs  []string
+	excludeGlobs  []string
 	wg            sync.WaitGroup
 	collectedData *CollectedData
+	feeds         feedList
+	httpConfig    HTTPConfig
+	stats         *crawlStats
+	frontier      *frontier
+	hooks         hookChain
+	index         Index
+}
+
+// Index is kept in sync with the pages the crawler fetches.
+type Index interface {
+	// UpdateDocument adds or re-indexes a fetched page.
+	UpdateDocument(doc *Document) error
+
+	// DeleteDocument removes a page that no longer exists.
+	DeleteDocument(url string) error
 }
 
 // CollectedData is a struct to represent the collected data.
 type CollectedData struct {
-	mutex sync.Mutex
-	data  map[string][]string
+	mutex     sync.Mutex
+	data      map[string][]string
+	documents []*Document
 }
 
 // NewCollectedData creates a new instance of CollectedData.
@@ -42,6 +59,26 @@ func (cd *CollectedData) AddData(url, data string) {
 	cd.data[url] = append(cd.data[url], data)
 }
 
+// AddDocument adds a parsed document to the collected data.
+func (cd *CollectedData) AddDocument(doc *Document) {
+	cd.mutex.Lock()
+	defer cd.mutex.Unlock()
+
+	// The parsed HTML is no longer needed once the hooks have run
+	doc.dom = nil
+
+	cd.data[doc.URL] = append(cd.data[doc.URL], doc.Text)
+	cd.documents = append(cd.documents, doc)
+}
+
+// GetDocuments returns the collected documents.
+func (cd *CollectedData) GetDocuments() []*Document {
+	cd.mutex.Lock()
+	defer cd.mutex.Unlock()
+
+	return append([]*Document{}, cd.documents...)
+}
+
 // GetData returns the collected data.
 func (cd *CollectedData) GetData() map[string][]string {
 	cd.mutex.Lock()
@@ -62,6 +99,8 @@ func NewCrawler(maxDepth, concurrency int) *Crawler {
 		concurrency:   concurrency,
 		rateLimiter:   time.Tick(500 * time.Millisecond),
 		collectedData: NewCollectedData(),
+		stats:         newCrawlStats(),
+		frontier:      newFrontier(DefaultTrapConfig()),
 	}
 }
 
@@ -72,71 +111,212 @@ func (c *Crawler) Crawl(url string, depth int) error {
 		return nil
 	}
 
-	// Throttle requests
-	<-c.rateLimiter
+	// Local directory trees are walked by the filesystem source
+	if strings.HasPrefix(url, "file://") {
+		return c.crawlFileSystem(strings.TrimPrefix(url, "file://"))
+	}
 
-	// Fetch the URL
-	res, err := c.fetch(url)
-	if err != nil {
-		return err
+	// Skip URLs that were already crawled or look like traps
+	url, ok := c.frontier.admit(url)
+	if !ok {
+		return nil
 	}
-	defer res.Body.Close()
 
-	// Parse the page with goquery
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	return c.crawl(url, depth)
+}
+
+// crawl fetches an admitted URL and schedules the links found on it.
+func (c *Crawler) crawl(url string, depth int) error {
+	// Fetch and parse the page
+	doc, err := c.fetchDocument(url)
+	if errors.Is(err, ErrSkip) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	// Extract the page text
-	pageText := doc.Find("body").Text()
-
 	// Process the page data (store or index the content)
-	c.collectedData.AddData(url, pageText)
+	c.addDocument(doc)
 
-	// Find all links in the page and recursively crawl them
-	doc.Find("a").Each(func(i int, s *goquery.Selection) {
-		link, exists := s.Attr("href")
-		if exists {
+	// Recursively crawl all links in the page
+	if depth < c.maxDepth {
+		for _, link := range doc.Links {
 			// Filter URLs if necessary
 			if c.filterDomain == "" || strings.Contains(link, c.filterDomain) {
-				c.wg.Add(1)
-				go c.Crawl(link, depth+1)
+				c.enqueue(link, depth+1)
 			}
 		}
-	})
+	}
 
 	return nil
 }
 
+// enqueue schedules a URL to be crawled in the background.
+func (c *Crawler) enqueue(url string, depth int) {
+	url, ok := c.frontier.admit(url)
+	if !ok {
+		return
+	}
+
+	c.wg.Add(1)
+	c.stats.enqueue()
+
+	go func() {
+		defer c.wg.Done()
+		c.stats.dequeue()
+		c.crawl(url, depth)
+	}()
+}
+
 // GetCollectedData retrieves the collected data from the Crawler.
 func (c *Crawler) GetCollectedData() map[string][]string {
 	return c.collectedData.GetData()
 }
 
+// GetDocuments retrieves the parsed documents from the Crawler.
+func (c *Crawler) GetDocuments() []*Document {
+	return c.collectedData.GetDocuments()
+}
+
+// SetIndex sets the index updated with every fetched page and every page found gone (404 or 410).
+func (c *Crawler) SetIndex(index Index) {
+	c.index = index
+}
+
 // SetFilterDomain sets the domain to filter URLs during crawling.
 func (c *Crawler) SetFilterDomain(domain string) {
 	c.filterDomain = domain
 }
 
+// siteHost reports whether a host belongs to the site being crawled, the host of the filter domain.
+func (c *Crawler) siteHost(host string) bool {
+	domain := c.filterDomain
+	if parsed, err := neturl.Parse(domain); err == nil && parsed.Host != "" {
+		domain = parsed.Hostname()
+	} else if i := strings.IndexAny(domain, ":/"); i >= 0 {
+		domain = domain[:i]
+	}
+	return domain != "" && (host == domain || strings.HasSuffix(host, "."+domain))
+}
+
+// SetTrapConfig sets the limits used to detect crawler traps.
+func (c *Crawler) SetTrapConfig(config TrapConfig) {
+	c.frontier = newFrontier(config)
+}
+
+// SetFileFilters sets the include and exclude globs used when crawling file:// trees.
+func (c *Crawler) SetFileFilters(include, exclude []string) {
+	c.includeGlobs = include
+	c.excludeGlobs = exclude
+}
+
 // Wait waits for all the crawling tasks to finish.
 func (c *Crawler) Wait() {
 	c.wg.Wait()
 }
 
-// fetch fetches the URL using the HTTP client.
-func (c *Crawler) fetch(url string) (*http.Response, error) {
+// crawlFileSystem collects the documents of a local directory tree.
+func (c *Crawler) crawlFileSystem(root string) error {
+	source := NewFileSystemSource(root)
+	source.SetInclude(c.includeGlobs...)
+	source.SetExclude(c.excludeGlobs...)
+
+	return source.Crawl(func(doc *Document) error {
+		c.collect(doc)
+		return nil
+	})
+}
+
+// fetchDocument throttles, fetches and parses a single page, running the hooks along the way.
+func (c *Crawler) fetchDocument(url string) (*Document, error) {
+	doc, err := c.fetchPage(url)
+	if err != nil {
+		return nil, err
+	}
+
+	// Let the hooks mutate the document
+	if err := c.hooks.afterParse(doc); err != nil {
+		return nil, c.fail(url, err)
+	}
+
+	return doc, nil
+}
+
+// fetchPage throttles, fetches and parses a single page, running the hooks up to the
+// parsing, so callers can complete the document before the after-parse hooks see it.
+func (c *Crawler) fetchPage(url string) (*Document, error) {
+	req, err := c.newRequest(url)
+	if err != nil {
+		return nil, c.fail(url, err)
+	}
+
+	// Let the hooks veto or modify the request
+	if err := c.hooks.beforeFetch(req); err != nil {
+		return nil, c.fail(url, err)
+	}
+
+	// Throttle requests
+	<-c.rateLimiter
+
+	started := c.stats.start()
+	host := req.URL.Hostname()
+
+	// Fetch the URL
+	res, err := c.fetch(req)
+	if err != nil {
+		c.stats.finish(host, started, 0, err)
+		return nil, c.fail(url, err)
+	}
+	body := &countingReader{ReadCloser: res.Body}
+	res.Body = body
+	defer res.Body.Close()
+
+	if err := c.hooks.afterFetch(res); err != nil {
+		c.stats.finish(host, started, body.n, nil)
+		return nil, c.fail(url, err)
+	}
+
+	// Parse the page into a document
+	doc, err := ParseDocument(url, res.Body)
+	if err != nil {
+		err = &ParseError{URL: url, Err: err}
+	}
+	c.stats.finish(host, started, body.n, err)
+	if err != nil {
+		return nil, c.fail(url, err)
+	}
+
+	return doc, nil
+}
+
+// addDocument stores a fetched document and passes it to the index.
+func (c *Crawler) addDocument(doc *Document) {
+	c.collectedData.AddDocument(doc)
+
+	if c.index != nil {
+		if err := c.index.UpdateDocument(doc); err != nil {
+			c.hooks.onError(doc.URL, err)
+		}
+	}
+}
+
+// newRequest creates a GET request carrying the crawler headers.
+func (c *Crawler) newRequest(url string) (*http.Request, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	// Set headers, e.g., User-Agent
-	// req.Header.Set("User-Agent", "our-crawler-name")
-	req.Header.Set("User-Agent", "Golang-Crawler/1.0 (+https://github.com/Mdromi/golang-search-engine/search-engine/)")
+	// Set the configured headers, e.g., User-Agent and credentials
+	c.httpConfig.hostConfig(req.URL.Hostname(), c.siteHost(req.URL.Hostname())).apply(req)
 
-	// Use the client to send the request with an increased timeout duration (e.g., 10 seconds)
-	c.client.Timeout = 10 * time.Second
+	return req, nil
+}
+
+// fetch sends the request using the HTTP client.
+func (c *Crawler) fetch(req *http.Request) (*http.Response, error) {
+	// Use the client to send the request
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
@@ -145,8 +325,17 @@ func (c *Crawler) fetch(url string) (*http.Response, error) {
 	// Check the HTTP response status code
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
-		return nil, fmt.Errorf("failed to fetch URL %s: status code %d", url, resp.StatusCode)
+		return nil, &StatusError{URL: req.URL.String(), StatusCode: resp.StatusCode}
 	}
 
 	return resp, nil
 }
+
+// hostname returns the host name of a URL, or an empty string if it cannot be parsed.
+func hostname(url string) string {
+	parsed, err := neturl.Parse(url)
+	if err != nil {
+		return ""
+	}
+	return parsed.Hostname()
+}
@@ -0,0 +1,75 @@
+package crawler
+
+import (
+	"io"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Document represents a parsed page ready to be analyzed and indexed.
+type Document struct {
+	URL      string
+	Title    string
+	Text     string
+	Links    []string
+	Date     time.Time
+	Metadata map[string]string
+	Fields   map[string]interface{} // typed fields such as price (float64) or datePublished (time.Time)
+	dom      *goquery.Document
+}
+
+// DOM returns the parsed HTML of the page, or nil for documents that were not parsed
+// from HTML. It is only available to hooks and is released once the document is collected.
+func (d *Document) DOM() *goquery.Document {
+	return d.dom
+}
+
+// ParseDocument parses an HTML page into a Document.
+func ParseDocument(url string, r io.Reader) (*Document, error) {
+	// Parse the page with goquery
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	document := &Document{
+		URL:      url,
+		Title:    strings.TrimSpace(doc.Find("title").First().Text()),
+		Text:     doc.Find("body").Text(),
+		Metadata: make(map[string]string),
+		Fields:   ExtractStructuredData(doc),
+		dom:      doc,
+	}
+
+	// Keep the meta description for display
+	if description, ok := doc.Find(`meta[name="description"]`).Attr("content"); ok {
+		document.Metadata["description"] = strings.TrimSpace(description)
+	}
+
+	// Collect all links in the page, resolved against the page URL
+	base, _ := neturl.Parse(url)
+	doc.Find("a").Each(func(i int, s *goquery.Selection) {
+		if link, exists := s.Attr("href"); exists {
+			document.Links = append(document.Links, resolveLink(base, link))
+		}
+	})
+
+	return document, nil
+}
+
+// resolveLink turns a possibly relative link into an absolute URL.
+func resolveLink(base *neturl.URL, link string) string {
+	link = strings.TrimSpace(link)
+	if base == nil {
+		return link
+	}
+
+	ref, err := neturl.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
@@ -0,0 +1,171 @@
+package crawler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Constants for the value types of extracted fields
+const (
+	ValueTypeString = "string"
+	ValueTypeNumber = "number"
+	ValueTypeDate   = "date"
+)
+
+// ExtractionProfile maps field names to CSS selector rules for a set of sites.
+type ExtractionProfile struct {
+	Hosts  []string             `yaml:"hosts"` // "example.com" or "*.example.com"
+	Fields map[string]FieldRule `yaml:"fields"`
+}
+
+// FieldRule describes how to extract a single field from a page.
+type FieldRule struct {
+	Selector  string `yaml:"selector"`  // CSS selector of the element(s) holding the value
+	Attribute string `yaml:"attribute"` // attribute to read; the element text is used if empty
+	Multiple  bool   `yaml:"multiple"`  // keep every match instead of only the first
+	Regex     string `yaml:"regex"`     // keep only the first capture group (or the whole match)
+	Type      string `yaml:"type"`      // "string" (default), "number" or "date"
+}
+
+// compiledRule is a FieldRule with its regular expression compiled.
+type compiledRule struct {
+	FieldRule
+	regex *regexp.Regexp
+}
+
+// extractionHook is a Hook that applies extraction profiles to parsed pages.
+type extractionHook struct {
+	NopHook
+	profiles map[string]map[string]compiledRule
+}
+
+// NewExtractionHook creates a Hook that fills document fields from the profile matching the page host.
+func NewExtractionHook(profiles []ExtractionProfile) (Hook, error) {
+	hook := &extractionHook{
+		profiles: make(map[string]map[string]compiledRule),
+	}
+
+	for _, profile := range profiles {
+		rules := make(map[string]compiledRule)
+		for field, rule := range profile.Fields {
+			if rule.Selector == "" {
+				return nil, fmt.Errorf("field %s: missing selector", field)
+			}
+			compiled := compiledRule{FieldRule: rule}
+			if rule.Regex != "" {
+				regex, err := regexp.Compile(rule.Regex)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: invalid regex: %w", field, err)
+				}
+				compiled.regex = regex
+			}
+			switch rule.Type {
+			case "", ValueTypeString, ValueTypeNumber, ValueTypeDate:
+			default:
+				return nil, fmt.Errorf("field %s: unknown type %q", field, rule.Type)
+			}
+			rules[field] = compiled
+		}
+
+		for _, host := range profile.Hosts {
+			hook.profiles[host] = rules
+		}
+	}
+
+	return hook, nil
+}
+
+// AfterParse implements the Hook interface.
+func (h *extractionHook) AfterParse(doc *Document) error {
+	dom := doc.DOM()
+	if dom == nil {
+		return nil
+	}
+
+	rules, ok := lookupHost(h.profiles, hostname(doc.URL))
+	if !ok {
+		return nil
+	}
+
+	if doc.Fields == nil {
+		doc.Fields = make(map[string]interface{})
+	}
+
+	for field, rule := range rules {
+		var values []interface{}
+		dom.Find(rule.Selector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+			if value, ok := rule.extract(s); ok {
+				values = append(values, value)
+			}
+			return rule.Multiple || len(values) == 0
+		})
+
+		switch {
+		case len(values) == 0:
+		case rule.Multiple:
+			doc.Fields[field] = values
+		default:
+			doc.Fields[field] = values[0]
+		}
+	}
+
+	return nil
+}
+
+// extract reads, post-processes and converts the value of one matched element.
+func (r compiledRule) extract(s *goquery.Selection) (interface{}, bool) {
+	var raw string
+	if r.Attribute != "" {
+		value, ok := s.Attr(r.Attribute)
+		if !ok {
+			return nil, false
+		}
+		raw = value
+	} else {
+		raw = s.Text()
+	}
+	raw = strings.TrimSpace(raw)
+
+	if r.regex != nil {
+		match := r.regex.FindStringSubmatch(raw)
+		if match == nil {
+			return nil, false
+		}
+		raw = match[0]
+		if len(match) > 1 {
+			raw = match[1]
+		}
+	}
+
+	if raw == "" {
+		return nil, false
+	}
+
+	switch r.Type {
+	case ValueTypeNumber:
+		value, ok := parseNumber(raw)
+		return value, ok
+	case ValueTypeDate:
+		value, ok := parseDate(raw)
+		return value, ok
+	default:
+		return raw, true
+	}
+}
+
+// SetExtractionProfiles registers a hook that extracts custom fields with the given profiles.
+func (c *Crawler) SetExtractionProfiles(profiles []ExtractionProfile) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	hook, err := NewExtractionHook(profiles)
+	if err != nil {
+		return err
+	}
+	c.AddHook(hook)
+	return nil
+}
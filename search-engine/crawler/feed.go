@@ -0,0 +1,341 @@
+package crawler
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// feedDateLayouts lists the date formats accepted in RSS and Atom feeds.
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// FeedEntry represents a single item of an RSS or Atom feed.
+type FeedEntry struct {
+	ID        string
+	Title     string
+	Link      string
+	Summary   string
+	Author    string
+	Published time.Time
+}
+
+// rssFeed mirrors the parts of an RSS 2.0 document we index.
+type rssFeed struct {
+	Items []struct {
+		GUID        string `xml:"guid"`
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		Author      string `xml:"author"`
+		Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		PubDate     string `xml:"pubDate"`
+	} `xml:"channel>item"`
+}
+
+// atomFeed mirrors the parts of an Atom document we index.
+type atomFeed struct {
+	Entries []struct {
+		ID    string `xml:"id"`
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+		Author    string `xml:"author>name"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+// ParseFeed parses an RSS 2.0 or Atom document into its entries.
+func ParseFeed(r io.Reader) ([]FeedEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Detect the feed format from the root element
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+
+	var entries []FeedEntry
+	switch root.XMLName.Local {
+	case "rss":
+		var feed rssFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, fmt.Errorf("failed to parse RSS feed: %w", err)
+		}
+		for _, item := range feed.Items {
+			entry := FeedEntry{
+				ID:        item.GUID,
+				Title:     strings.TrimSpace(item.Title),
+				Link:      strings.TrimSpace(item.Link),
+				Summary:   stripHTML(item.Description),
+				Author:    firstNonEmpty(item.Creator, item.Author),
+				Published: parseFeedDate(item.PubDate),
+			}
+			entries = append(entries, entry)
+		}
+	case "feed":
+		var feed atomFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, fmt.Errorf("failed to parse Atom feed: %w", err)
+		}
+		for _, item := range feed.Entries {
+			entry := FeedEntry{
+				ID:        item.ID,
+				Title:     strings.TrimSpace(item.Title),
+				Summary:   stripHTML(firstNonEmpty(item.Summary, item.Content)),
+				Author:    strings.TrimSpace(item.Author),
+				Published: parseFeedDate(firstNonEmpty(item.Published, item.Updated)),
+			}
+			for _, link := range item.Links {
+				if link.Rel == "" || link.Rel == "alternate" {
+					entry.Link = link.Href
+					break
+				}
+			}
+			entries = append(entries, entry)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported feed format %q", root.XMLName.Local)
+	}
+
+	// Fall back to the link as the entry identifier
+	for i := range entries {
+		if entries[i].ID == "" {
+			entries[i].ID = entries[i].Link
+		}
+	}
+
+	return entries, nil
+}
+
+// Document converts the entry into a Document holding its summary.
+func (e FeedEntry) Document() *Document {
+	doc := &Document{
+		URL:      e.Link,
+		Title:    e.Title,
+		Text:     e.Summary,
+		Date:     e.Published,
+		Metadata: make(map[string]string),
+	}
+	e.annotate(doc)
+	return doc
+}
+
+// annotate adds the entry metadata to a document fetched from the entry link.
+func (e FeedEntry) annotate(doc *Document) {
+	if doc.Metadata == nil {
+		doc.Metadata = make(map[string]string)
+	}
+	if e.Author != "" {
+		doc.Metadata["author"] = e.Author
+	}
+	if !e.Published.IsZero() {
+		doc.Date = e.Published
+		doc.Metadata["published"] = e.Published.Format(time.RFC3339)
+	}
+}
+
+// Feed keeps the polling state of a single feed.
+type Feed struct {
+	URL          string
+	etag         string
+	lastModified string
+	seen         map[string]bool // IDs of the entries in the last polled feed document
+}
+
+// feedList is the set of feeds registered with a crawler.
+type feedList struct {
+	mutex sync.Mutex
+	feeds []*Feed
+}
+
+// AddFeed registers an RSS or Atom feed to be polled by PollFeeds.
+func (c *Crawler) AddFeed(url string) {
+	c.feeds.mutex.Lock()
+	defer c.feeds.mutex.Unlock()
+
+	c.feeds.feeds = append(c.feeds.feeds, &Feed{
+		URL:  url,
+		seen: make(map[string]bool),
+	})
+}
+
+// PollFeeds polls every registered feed once and collects its new entries.
+// Entries whose linked page cannot be fetched are indexed from their summary.
+// Feeds that fail are reported to the OnError hooks.
+func (c *Crawler) PollFeeds() error {
+	c.feeds.mutex.Lock()
+	defer c.feeds.mutex.Unlock()
+
+	var errs []string
+	for _, feed := range c.feeds.feeds {
+		if err := c.pollFeed(feed); err != nil {
+			c.hooks.onError(feed.URL, err)
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to poll feeds: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// RunFeeds polls the registered feeds on the given interval until the context is cancelled.
+// afterPoll, if not nil, is called after every round, e.g. to index the new entries.
+// Feeds that fail are reported to the OnError hooks and polled again in the next round.
+func (c *Crawler) RunFeeds(ctx context.Context, interval time.Duration, afterPoll func() error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Failures were reported to the hooks and are retried in the next round
+		c.PollFeeds()
+		if afterPoll != nil {
+			if err := afterPoll(); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollFeed fetches a feed with a conditional GET and collects the entries not seen before.
+func (c *Crawler) pollFeed(feed *Feed) error {
+	<-c.rateLimiter
+
+	req, err := c.newRequest(feed.URL)
+	if err != nil {
+		return err
+	}
+	if feed.etag != "" {
+		req.Header.Set("If-None-Match", feed.etag)
+	}
+	if feed.lastModified != "" {
+		req.Header.Set("If-Modified-Since", feed.lastModified)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// Nothing changed since the last poll
+	if res.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch feed %s: status code %d", feed.URL, res.StatusCode)
+	}
+
+	entries, err := ParseFeed(res.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", feed.URL, err)
+	}
+
+	feed.etag = res.Header.Get("ETag")
+	feed.lastModified = res.Header.Get("Last-Modified")
+
+	// Only remember the entries still in the feed, so the set stays as small as the feed
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.ID == "" || seen[entry.ID] {
+			continue
+		}
+		seen[entry.ID] = true
+		if !feed.seen[entry.ID] {
+			c.crawlFeedEntry(entry)
+		}
+	}
+	feed.seen = seen
+
+	return nil
+}
+
+// crawlFeedEntry fetches the page an entry links to, falling back to the entry summary.
+func (c *Crawler) crawlFeedEntry(entry FeedEntry) {
+	if entry.Link == "" {
+		return
+	}
+
+	doc, err := c.fetchPage(entry.Link)
+	if errors.Is(err, ErrSkip) {
+		return
+	}
+	if err != nil {
+		c.collect(entry.Document())
+		return
+	}
+
+	// Keep the summary searchable alongside the page text, before the hooks see the page
+	if doc.Title == "" {
+		doc.Title = entry.Title
+	}
+	doc.Text = entry.Summary + "\n" + doc.Text
+	entry.annotate(doc)
+
+	c.collect(doc)
+}
+
+// parseFeedDate parses a feed date in any of the accepted layouts.
+func parseFeedDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+	return time.Time{}
+}
+
+// stripHTML returns the text content of an HTML fragment.
+func stripHTML(fragment string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fragment))
+	if err != nil {
+		return strings.TrimSpace(fragment)
+	}
+	return strings.TrimSpace(doc.Text())
+}
+
+// firstNonEmpty returns the first of its arguments that is not blank.
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
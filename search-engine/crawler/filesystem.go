@@ -0,0 +1,223 @@
+package crawler
+
+import (
+	"bytes"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Constants for detected file types
+const (
+	FileTypeHTML     = "html"
+	FileTypeMarkdown = "markdown"
+	FileTypeText     = "text"
+)
+
+// ignoreFileNames lists the .gitignore-style files honored while walking a tree.
+var ignoreFileNames = []string{".gitignore", ".ignore"}
+
+// textExtensions maps file extensions to their file type.
+var textExtensions = map[string]string{
+	".html":     FileTypeHTML,
+	".htm":      FileTypeHTML,
+	".md":       FileTypeMarkdown,
+	".markdown": FileTypeMarkdown,
+	".txt":      FileTypeText,
+	".rst":      FileTypeText,
+	".adoc":     FileTypeText,
+}
+
+// FileSystemSource crawls documents from a local directory tree.
+type FileSystemSource struct {
+	root    string
+	include []string
+	exclude []string
+}
+
+// NewFileSystemSource creates a new instance of FileSystemSource rooted at the given directory.
+func NewFileSystemSource(root string) *FileSystemSource {
+	return &FileSystemSource{
+		root: root,
+	}
+}
+
+// SetInclude sets the glob patterns a file must match to be crawled.
+// An empty list includes every file.
+func (s *FileSystemSource) SetInclude(patterns ...string) {
+	s.include = patterns
+}
+
+// SetExclude sets the glob patterns of files and directories to skip.
+func (s *FileSystemSource) SetExclude(patterns ...string) {
+	s.exclude = patterns
+}
+
+// Crawl walks the directory tree and passes every parsed document to fn.
+func (s *FileSystemSource) Crawl(fn func(*Document) error) error {
+	root, err := filepath.Abs(s.root)
+	if err != nil {
+		return err
+	}
+
+	// Ignore rules keyed by the slash-separated directory they were found in
+	rules := make(map[string][]ignoreRule)
+
+	return filepath.WalkDir(root, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			if rel != "." && (entry.Name() == ".git" || s.skipped(rules, rel, true)) {
+				return filepath.SkipDir
+			}
+			return s.loadIgnoreRules(rules, filePath, rel)
+		}
+
+		if !entry.Type().IsRegular() || s.skipped(rules, rel, false) || !s.included(rel) {
+			return nil
+		}
+
+		doc, err := s.readFile(filePath)
+		if err != nil || doc == nil {
+			return err
+		}
+		return fn(doc)
+	})
+}
+
+// loadIgnoreRules reads the ignore files of a directory.
+func (s *FileSystemSource) loadIgnoreRules(rules map[string][]ignoreRule, dir, rel string) error {
+	for _, name := range ignoreFileNames {
+		dirRules, err := parseIgnoreFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		rules[rel] = append(rules[rel], dirRules...)
+	}
+	return nil
+}
+
+// skipped reports whether a path is excluded by the exclude globs or an ignore file.
+func (s *FileSystemSource) skipped(rules map[string][]ignoreRule, rel string, isDir bool) bool {
+	for _, pattern := range s.exclude {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+
+	// Collect the directories from the root down to the path's parent
+	ancestors := []string{"."}
+	if dir := path.Dir(rel); dir != "." {
+		parts := strings.Split(dir, "/")
+		for i := range parts {
+			ancestors = append(ancestors, strings.Join(parts[:i+1], "/"))
+		}
+	}
+
+	// Apply the rules from the root down, letting the last matching rule win
+	ignored := false
+	for _, ancestor := range ancestors {
+		local := rel
+		if ancestor != "." {
+			local = strings.TrimPrefix(rel, ancestor+"/")
+		}
+		for _, rule := range rules[ancestor] {
+			if rule.matches(local, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// included reports whether a file matches the include globs.
+func (s *FileSystemSource) included(rel string) bool {
+	if len(s.include) == 0 {
+		return true
+	}
+	for _, pattern := range s.include {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// readFile parses a single file into a Document, or returns nil for unsupported file types.
+func (s *FileSystemSource) readFile(filePath string) (*Document, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	fileType := DetectFileType(filePath, content)
+	if fileType == "" {
+		return nil, nil
+	}
+
+	url := "file://" + filepath.ToSlash(filePath)
+
+	var doc *Document
+	if fileType == FileTypeHTML {
+		doc, err = ParseDocument(url, bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		doc = &Document{
+			URL:      url,
+			Title:    textTitle(filepath.Base(filePath), fileType, content),
+			Text:     string(content),
+			Metadata: make(map[string]string),
+		}
+	}
+	doc.Metadata["fileType"] = fileType
+
+	// Use the file modification time as the document date
+	if info, err := os.Stat(filePath); err == nil {
+		doc.Date = info.ModTime()
+	}
+
+	return doc, nil
+}
+
+// DetectFileType determines the type of a file from its extension, falling back
+// to content sniffing. It returns an empty string for binary files.
+func DetectFileType(filePath string, content []byte) string {
+	if fileType, ok := textExtensions[strings.ToLower(filepath.Ext(filePath))]; ok {
+		return fileType
+	}
+
+	contentType := http.DetectContentType(content)
+	switch {
+	case strings.HasPrefix(contentType, "text/html"):
+		return FileTypeHTML
+	case strings.HasPrefix(contentType, "text/"):
+		return FileTypeText
+	default:
+		return ""
+	}
+}
+
+// textTitle picks a title for a plain-text document, preferring the first Markdown heading.
+func textTitle(name, fileType string, content []byte) string {
+	if fileType == FileTypeMarkdown {
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.HasPrefix(line, "# ") {
+				return strings.TrimSpace(line[2:])
+			}
+		}
+	}
+	return name
+}
@@ -0,0 +1,207 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Constants for crawler trap kinds
+const (
+	TrapURLLength         = "url-length"
+	TrapRepeatingPath     = "repeating-path"
+	TrapQueryPermutations = "query-permutations"
+	TrapPathPattern       = "path-pattern"
+)
+
+// sessionParams lists query parameters that only carry session IDs.
+var sessionParams = map[string]bool{
+	"jsessionid": true,
+	"phpsessid":  true,
+	"sid":        true,
+	"sessionid":  true,
+	"session_id": true,
+}
+
+// TrapConfig holds the limits used to detect crawler traps.
+// A zero limit disables the corresponding heuristic.
+type TrapConfig struct {
+	MaxURLLength        int `yaml:"maxURLLength"`        // longest URL that is crawled
+	MaxRepeatedSegments int `yaml:"maxRepeatedSegments"` // how often one path segment may repeat
+	MaxQueryVariants    int `yaml:"maxQueryVariants"`    // distinct query strings per path
+	MaxPatternCount     int `yaml:"maxPatternCount"`     // URLs per path pattern, e.g. /calendar/{n}/{n}
+}
+
+// DefaultTrapConfig returns the default crawler trap limits.
+func DefaultTrapConfig() TrapConfig {
+	return TrapConfig{
+		MaxURLLength:        2048,
+		MaxRepeatedSegments: 3,
+		MaxQueryVariants:    100,
+		MaxPatternCount:     1000,
+	}
+}
+
+// Trap describes a detected crawler trap.
+type Trap struct {
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern"`
+	Example string `json:"example"`
+	Blocked int    `json:"blocked"`
+}
+
+// frontier decides which discovered URLs are crawled.
+// It deduplicates URLs and blocks URL spaces that look like crawler traps.
+type frontier struct {
+	mutex         sync.Mutex
+	config        TrapConfig
+	visited       map[string]bool
+	queryVariants map[string]int
+	patterns      map[string]int
+	traps         map[string]*Trap
+}
+
+// newFrontier creates a new instance of frontier.
+func newFrontier(config TrapConfig) *frontier {
+	return &frontier{
+		config:        config,
+		visited:       make(map[string]bool),
+		queryVariants: make(map[string]int),
+		patterns:      make(map[string]int),
+		traps:         make(map[string]*Trap),
+	}
+}
+
+// admit normalizes a URL and reports whether it should be crawled.
+func (f *frontier) admit(rawURL string) (string, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return rawURL, false
+	}
+	normalized := normalizeURL(parsed)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.visited[normalized] {
+		return normalized, false
+	}
+
+	if kind, pattern := f.detectTrap(parsed, normalized); kind != "" {
+		f.recordTrap(kind, pattern, normalized)
+		return normalized, false
+	}
+
+	f.visited[normalized] = true
+	return normalized, true
+}
+
+// detectTrap applies the trap heuristics to a new URL and returns the trap kind and pattern, if any.
+func (f *frontier) detectTrap(parsed *url.URL, normalized string) (string, string) {
+	hostPath := parsed.Host + parsed.EscapedPath()
+
+	if f.config.MaxURLLength > 0 && len(normalized) > f.config.MaxURLLength {
+		return TrapURLLength, parsed.Host
+	}
+
+	if f.config.MaxRepeatedSegments > 0 {
+		counts := make(map[string]int)
+		for _, segment := range strings.Split(parsed.Path, "/") {
+			if segment == "" {
+				continue
+			}
+			counts[segment]++
+			if counts[segment] > f.config.MaxRepeatedSegments {
+				return TrapRepeatingPath, parsed.Host + "/**/" + segment + "/**"
+			}
+		}
+	}
+
+	if f.config.MaxQueryVariants > 0 && parsed.RawQuery != "" {
+		if f.queryVariants[hostPath] >= f.config.MaxQueryVariants {
+			return TrapQueryPermutations, hostPath + "?*"
+		}
+		f.queryVariants[hostPath]++
+	}
+
+	if f.config.MaxPatternCount > 0 {
+		pattern := parsed.Host + pathPattern(parsed.Path)
+		if f.patterns[pattern] >= f.config.MaxPatternCount {
+			return TrapPathPattern, pattern
+		}
+		f.patterns[pattern]++
+	}
+
+	return "", ""
+}
+
+// recordTrap remembers a blocked URL under its trap.
+func (f *frontier) recordTrap(kind, pattern, example string) {
+	key := kind + " " + pattern
+	trap, ok := f.traps[key]
+	if !ok {
+		trap = &Trap{Kind: kind, Pattern: pattern, Example: example}
+		f.traps[key] = trap
+	}
+	trap.Blocked++
+}
+
+// detectedTraps returns the traps detected so far, most blocked first.
+func (f *frontier) detectedTraps() []Trap {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	traps := make([]Trap, 0, len(f.traps))
+	for _, trap := range f.traps {
+		traps = append(traps, *trap)
+	}
+	sort.Slice(traps, func(i, j int) bool {
+		if traps[i].Blocked != traps[j].Blocked {
+			return traps[i].Blocked > traps[j].Blocked
+		}
+		return traps[i].Pattern < traps[j].Pattern
+	})
+	return traps
+}
+
+// normalizeURL canonicalizes a URL so equivalent URLs are crawled once.
+// It lowercases the scheme and host, drops the fragment and session parameters
+// and sorts the query parameters.
+func normalizeURL(parsed *url.URL) string {
+	normalized := *parsed
+	normalized.Scheme = strings.ToLower(normalized.Scheme)
+	normalized.Host = strings.ToLower(normalized.Host)
+	normalized.Fragment = ""
+	normalized.RawFragment = ""
+
+	// Drop ";jsessionid=..." style path parameters
+	if i := strings.Index(strings.ToLower(normalized.Path), ";jsessionid="); i >= 0 {
+		normalized.Path = normalized.Path[:i]
+		normalized.RawPath = ""
+	}
+
+	if normalized.RawQuery != "" {
+		query := normalized.Query()
+		for name := range query {
+			if sessionParams[strings.ToLower(name)] {
+				query.Del(name)
+			}
+		}
+		normalized.RawQuery = query.Encode()
+	}
+
+	return normalized.String()
+}
+
+// pathPattern replaces the variable segments of a path, i.e. those containing digits, with "{n}".
+func pathPattern(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.IndexFunc(segment, unicode.IsDigit) >= 0 {
+			segments[i] = "{n}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,138 @@
+package crawler
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrSkip can be returned by a hook to drop the current URL or document without reporting an error.
+var ErrSkip = errors.New("crawler: skipped by hook")
+
+// Hook lets callers run their own logic at each stage of a crawl.
+// Embed NopHook to implement only the callbacks you need.
+type Hook interface {
+	// BeforeFetch is called before a page is requested. It may modify the request
+	// or return ErrSkip to veto it.
+	BeforeFetch(req *http.Request) error
+
+	// AfterFetch is called with the response of a successful fetch before it is parsed.
+	AfterFetch(res *http.Response) error
+
+	// AfterParse is called with every parsed document before it is collected.
+	// It may mutate the document, e.g. to add metadata, scrub text or prune links.
+	AfterParse(doc *Document) error
+
+	// OnError is called when fetching, parsing or another hook fails.
+	OnError(url string, err error)
+}
+
+// NopHook is a Hook that does nothing.
+type NopHook struct{}
+
+func (NopHook) BeforeFetch(req *http.Request) error { return nil }
+func (NopHook) AfterFetch(res *http.Response) error { return nil }
+func (NopHook) AfterParse(doc *Document) error      { return nil }
+func (NopHook) OnError(url string, err error)       {}
+
+// HookFuncs adapts optional functions to the Hook interface.
+type HookFuncs struct {
+	BeforeFetchFunc func(req *http.Request) error
+	AfterFetchFunc  func(res *http.Response) error
+	AfterParseFunc  func(doc *Document) error
+	OnErrorFunc     func(url string, err error)
+}
+
+func (h HookFuncs) BeforeFetch(req *http.Request) error {
+	if h.BeforeFetchFunc == nil {
+		return nil
+	}
+	return h.BeforeFetchFunc(req)
+}
+
+func (h HookFuncs) AfterFetch(res *http.Response) error {
+	if h.AfterFetchFunc == nil {
+		return nil
+	}
+	return h.AfterFetchFunc(res)
+}
+
+func (h HookFuncs) AfterParse(doc *Document) error {
+	if h.AfterParseFunc == nil {
+		return nil
+	}
+	return h.AfterParseFunc(doc)
+}
+
+func (h HookFuncs) OnError(url string, err error) {
+	if h.OnErrorFunc != nil {
+		h.OnErrorFunc(url, err)
+	}
+}
+
+// hookChain runs hooks in registration order, stopping at the first error.
+type hookChain []Hook
+
+func (hooks hookChain) beforeFetch(req *http.Request) error {
+	for _, hook := range hooks {
+		if err := hook.BeforeFetch(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hooks hookChain) afterFetch(res *http.Response) error {
+	for _, hook := range hooks {
+		if err := hook.AfterFetch(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hooks hookChain) afterParse(doc *Document) error {
+	for _, hook := range hooks {
+		if err := hook.AfterParse(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hooks hookChain) onError(url string, err error) {
+	for _, hook := range hooks {
+		hook.OnError(url, err)
+	}
+}
+
+// AddHook registers a hook. Hooks run in the order they were added and must be
+// registered before crawling starts.
+func (c *Crawler) AddHook(hook Hook) {
+	c.hooks = append(c.hooks, hook)
+}
+
+// fail reports an error to the hooks unless it is ErrSkip, removes pages that are gone from the index, and returns it.
+func (c *Crawler) fail(url string, err error) error {
+	if errors.Is(err, ErrSkip) {
+		return err
+	}
+	c.hooks.onError(url, err)
+
+	// Pages that are gone are removed from the index
+	var statusErr *StatusError
+	if c.index != nil && errors.As(err, &statusErr) && (statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusGone) {
+		if err := c.index.DeleteDocument(url); err != nil {
+			c.hooks.onError(url, err)
+		}
+	}
+	return err
+}
+
+// collect runs the after-parse hooks on a document and stores it.
+func (c *Crawler) collect(doc *Document) {
+	if err := c.hooks.afterParse(doc); err != nil {
+		c.fail(doc.URL, err)
+		return
+	}
+	c.addDocument(doc)
+}
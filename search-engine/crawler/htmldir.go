@@ -0,0 +1,65 @@
+package crawler
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadHTMLDir walks a directory of saved HTML files and passes every parsed page to fn.
+// Pages are addressed by baseURL joined with their relative path, or by a file:// URL
+// if baseURL is empty.
+func ReadHTMLDir(dir, baseURL string, fn func(*Document) error) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Only consider HTML files
+		ext := strings.ToLower(filepath.Ext(path))
+		if entry.IsDir() || (ext != ".html" && ext != ".htm") {
+			return nil
+		}
+
+		url, err := fileURL(dir, path, baseURL)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		doc, err := ParseDocument(url, file)
+		if err != nil {
+			return err
+		}
+
+		// Use the file modification time as the document date
+		if info, err := entry.Info(); err == nil {
+			doc.Date = info.ModTime()
+		}
+
+		return fn(doc)
+	})
+}
+
+// fileURL builds the URL under which a file inside dir is indexed.
+func fileURL(dir, path, baseURL string) (string, error) {
+	if baseURL == "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		return "file://" + filepath.ToSlash(abs), nil
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + filepath.ToSlash(rel), nil
+}
@@ -0,0 +1,310 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultUserAgent is sent when no User-Agent is configured.
+const defaultUserAgent = "Golang-Crawler/1.0 (+https://github.com/Mdromi/golang-search-engine/search-engine/)"
+
+// safeHeaders are the job headers sent to every host; any other header may carry a secret.
+var safeHeaders = map[string]bool{
+	"Accept":          true,
+	"Accept-Language": true,
+}
+
+// HTTPConfig holds the HTTP settings of a crawl job.
+// Hosts overrides the job settings for individual hosts ("example.com" or "*.example.com").
+// The job's cookies, credentials and headers other than Accept and Accept-Language are
+// only sent to the hosts listed under Hosts and to the host of the filter domain, never to
+// other sites the crawler follows links to. Secrets may reference environment variables as ${NAME}.
+type HTTPConfig struct {
+	HostHTTPConfig `yaml:",inline"`
+	Timeout        time.Duration             `yaml:"timeout"`
+	Hosts          map[string]HostHTTPConfig `yaml:"hosts"`
+}
+
+// HostHTTPConfig holds the HTTP settings that can be set per host.
+type HostHTTPConfig struct {
+	UserAgent   string            `yaml:"userAgent"`
+	Headers     map[string]string `yaml:"headers"`
+	Cookies     map[string]string `yaml:"cookies"` // sent on every request, or seeded into the cookie jar for hosts
+	BasicAuth   *BasicAuth        `yaml:"basicAuth"`
+	BearerToken string            `yaml:"bearerToken"`
+	Proxy       string            `yaml:"proxy"` // http://, https:// or socks5:// proxy URL
+	TLS         TLSConfig         `yaml:"tls"`
+}
+
+// BasicAuth holds HTTP basic authentication credentials.
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// TLSConfig holds the TLS options for HTTPS connections.
+type TLSConfig struct {
+	CAFile             string `yaml:"caFile"`   // PEM bundle of additional trusted CAs
+	CertFile           string `yaml:"certFile"` // client certificate
+	KeyFile            string `yaml:"keyFile"`  // client certificate key
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify"`
+}
+
+// SetHTTPConfig configures the HTTP client of the crawler.
+func (c *Crawler) SetHTTPConfig(config HTTPConfig) error {
+	transport, err := newTransport(config.HostHTTPConfig)
+	if err != nil {
+		return err
+	}
+
+	// Hosts with their own proxy or TLS settings get their own transport
+	hostTransports := make(map[string]http.RoundTripper)
+	for host, hostConfig := range config.Hosts {
+		if hostConfig.Proxy == "" && hostConfig.TLS == (TLSConfig{}) {
+			continue
+		}
+		merged := config.HostHTTPConfig.merge(hostConfig)
+		hostTransport, err := newTransport(merged)
+		if err != nil {
+			return fmt.Errorf("host %s: %w", host, err)
+		}
+		hostTransports[host] = hostTransport
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+
+	// Seed the cookie jar with the per-host cookies; wildcards become domain cookies
+	for host, hostConfig := range config.Hosts {
+		domain := ""
+		if strings.HasPrefix(host, "*.") {
+			host = strings.TrimPrefix(host, "*.")
+			domain = host
+		}
+
+		var cookies []*http.Cookie
+		for name, value := range hostConfig.Cookies {
+			cookies = append(cookies, &http.Cookie{Name: name, Value: os.ExpandEnv(value), Domain: domain, Path: "/"})
+		}
+		if len(cookies) > 0 {
+			jar.SetCookies(&url.URL{Scheme: "https", Host: host, Path: "/"}, cookies)
+		}
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = c.client.Timeout
+	}
+
+	c.httpConfig = config
+	c.client = &http.Client{
+		Timeout: timeout,
+		Jar:     jar,
+		Transport: &hostRoundTripper{
+			defaultTransport: transport,
+			hosts:            hostTransports,
+		},
+	}
+	return nil
+}
+
+// hostConfig returns the effective settings for a host. Hosts neither listed nor
+// trusted as the site being crawled get the job settings without its secrets.
+func (config HTTPConfig) hostConfig(host string, trusted bool) HostHTTPConfig {
+	if hostConfig, ok := lookupHost(config.Hosts, host); ok {
+		return config.HostHTTPConfig.merge(hostConfig)
+	}
+	if trusted {
+		return config.HostHTTPConfig
+	}
+	return config.HostHTTPConfig.withoutSecrets()
+}
+
+// HasSecrets reports whether the job settings hold anything withheld from untrusted hosts.
+func (config HTTPConfig) HasSecrets() bool {
+	stripped := config.HostHTTPConfig.withoutSecrets()
+	return len(config.Cookies) > 0 || config.BasicAuth != nil || config.BearerToken != "" ||
+		len(stripped.Headers) != len(config.Headers)
+}
+
+// withoutSecrets returns the settings without cookies, credentials and unsafe headers.
+func (config HostHTTPConfig) withoutSecrets() HostHTTPConfig {
+	var headers map[string]string
+	for name, value := range config.Headers {
+		if !safeHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		headers[name] = value
+	}
+
+	config.Headers = headers
+	config.Cookies = nil
+	config.BasicAuth = nil
+	config.BearerToken = ""
+	return config
+}
+
+// merge returns the settings with the non-empty fields of override applied.
+func (config HostHTTPConfig) merge(override HostHTTPConfig) HostHTTPConfig {
+	merged := config
+
+	if override.UserAgent != "" {
+		merged.UserAgent = override.UserAgent
+	}
+	if override.BasicAuth != nil {
+		merged.BasicAuth = override.BasicAuth
+	}
+	if override.BearerToken != "" {
+		merged.BearerToken = override.BearerToken
+	}
+	if override.Proxy != "" {
+		merged.Proxy = override.Proxy
+	}
+	if override.TLS != (TLSConfig{}) {
+		merged.TLS = override.TLS
+	}
+
+	// Host cookies live in the cookie jar, so only the headers are merged
+	merged.Headers = mergeStrings(config.Headers, override.Headers)
+	return merged
+}
+
+// apply sets the configured headers, cookies and credentials on a request.
+func (config HostHTTPConfig) apply(req *http.Request) {
+	userAgent := config.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	for name, value := range config.Headers {
+		req.Header.Set(name, os.ExpandEnv(value))
+	}
+	for name, value := range config.Cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: os.ExpandEnv(value)})
+	}
+
+	if config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+os.ExpandEnv(config.BearerToken))
+	} else if config.BasicAuth != nil {
+		req.SetBasicAuth(os.ExpandEnv(config.BasicAuth.Username), os.ExpandEnv(config.BasicAuth.Password))
+	}
+}
+
+// hostRoundTripper sends requests through the transport configured for their host.
+type hostRoundTripper struct {
+	defaultTransport http.RoundTripper
+	hosts            map[string]http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (rt *hostRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if transport, ok := lookupHost(rt.hosts, req.URL.Hostname()); ok {
+		return transport.RoundTrip(req)
+	}
+	return rt.defaultTransport.RoundTrip(req)
+}
+
+// newTransport builds an HTTP transport with the given proxy and TLS settings.
+func newTransport(config HostHTTPConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(os.ExpandEnv(config.Proxy))
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig, err := config.TLS.build()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return transport, nil
+}
+
+// build creates the tls.Config described by the options, or nil for the defaults.
+func (config TLSConfig) build() (*tls.Config, error) {
+	if config == (TLSConfig{}) {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// lookupHost finds the entry for a host, trying an exact match before "*." wildcards.
+func lookupHost[T any](hosts map[string]T, host string) (T, bool) {
+	if value, ok := hosts[host]; ok {
+		return value, true
+	}
+	for domain := host; strings.Contains(domain, "."); {
+		domain = domain[strings.Index(domain, ".")+1:]
+		if value, ok := hosts["*."+domain]; ok {
+			return value, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+// mergeStrings returns the union of two string maps, preferring values from override.
+func mergeStrings(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]string, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
+}
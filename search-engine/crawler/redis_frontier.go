@@ -0,0 +1,284 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pushScript adds a URL to its host queue unless it was seen before, and keeps the seen
+// set for the seen TTL after the last URL added to it.
+// KEYS: seen set, host queue, ready hosts, next fetch times. ARGV: url, item, host, now, seen TTL.
+var pushScript = redis.NewScript(`
+if redis.call('SADD', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+redis.call('RPUSH', KEYS[2], ARGV[2])
+local ready = tonumber(redis.call('HGET', KEYS[4], ARGV[3]) or ARGV[4])
+if ready < tonumber(ARGV[4]) then
+	ready = tonumber(ARGV[4])
+end
+redis.call('ZADD', KEYS[3], 'NX', ready, ARGV[3])
+return 1
+`)
+
+// leaseScript requeues expired leases, then leases the next URL of a host that is ready.
+// KEYS: ready hosts, leases, lease data, next fetch times, lease counter.
+// ARGV: key prefix, worker, now, lease TTL, host delay (all times in milliseconds).
+var leaseScript = redis.NewScript(`
+local now = tonumber(ARGV[3])
+
+for _, lease in ipairs(redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now)) do
+	local data = redis.call('HGET', KEYS[3], lease)
+	if data then
+		local sep = string.find(data, '\n', 1, true)
+		local host = string.sub(data, 1, sep - 1)
+		redis.call('LPUSH', ARGV[1] .. 'queue:' .. host, string.sub(data, sep + 1))
+		redis.call('ZADD', KEYS[1], 'NX', now, host)
+	end
+	redis.call('ZREM', KEYS[2], lease)
+	redis.call('HDEL', KEYS[3], lease)
+end
+
+while true do
+	local hosts = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now, 'LIMIT', 0, 1)
+	if #hosts == 0 then
+		return false
+	end
+	local host = hosts[1]
+	local queue = ARGV[1] .. 'queue:' .. host
+	local item = redis.call('LPOP', queue)
+	local next = now + tonumber(ARGV[5])
+	redis.call('HSET', KEYS[4], host, next)
+	if redis.call('LLEN', queue) == 0 then
+		redis.call('ZREM', KEYS[1], host)
+	else
+		redis.call('ZADD', KEYS[1], next, host)
+	end
+	if item then
+		local lease = ARGV[2] .. ':' .. redis.call('INCR', KEYS[5])
+		redis.call('ZADD', KEYS[2], now + tonumber(ARGV[4]), lease)
+		redis.call('HSET', KEYS[3], lease, host .. '\n' .. item)
+		return {lease, item}
+	end
+end
+`)
+
+// RedisFrontierConfig holds the settings of a Redis-backed shared frontier.
+type RedisFrontierConfig struct {
+	JobID     string        `yaml:"jobID"`     // crawl job shared by all workers
+	WorkerID  string        `yaml:"workerID"`  // unique name of this worker
+	LeaseTTL  time.Duration `yaml:"leaseTTL"`  // how long a worker may hold a URL before it is requeued
+	HostDelay time.Duration `yaml:"hostDelay"` // minimum delay between two fetches from the same host
+	SeenTTL   time.Duration `yaml:"seenTTL"`   // how long URLs stay seen after the last new one, before the job crawls them again
+}
+
+// RedisFrontier is a crawl frontier shared by several crawler processes through Redis.
+// It keeps a global visited set, one queue per host and leases on in-flight URLs,
+// so politeness holds across workers and URLs of dead workers are recrawled.
+// The visited set expires SeenTTL after the last new URL, so a job run again later,
+// e.g. on a schedule, crawls its pages again; Reset forgets them at once.
+type RedisFrontier struct {
+	client *redis.Client
+	config RedisFrontierConfig
+	prefix string
+}
+
+// Lease is a URL leased to a worker.
+type Lease struct {
+	ID    string
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// NewRedisFrontier creates a new instance of RedisFrontier.
+func NewRedisFrontier(client *redis.Client, config RedisFrontierConfig) *RedisFrontier {
+	if config.JobID == "" {
+		config.JobID = "default"
+	}
+	if config.LeaseTTL <= 0 {
+		config.LeaseTTL = 5 * time.Minute
+	}
+	if config.HostDelay < 0 {
+		config.HostDelay = 0
+	}
+	if config.SeenTTL <= 0 {
+		config.SeenTTL = 24 * time.Hour
+	}
+
+	return &RedisFrontier{
+		client: client,
+		config: config,
+		prefix: "crawl:" + config.JobID + ":",
+	}
+}
+
+// Push adds a URL to the frontier unless any worker has seen it before.
+func (f *RedisFrontier) Push(ctx context.Context, rawURL string, depth int) (bool, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false, err
+	}
+	normalized := normalizeURL(parsed)
+	host := strings.ToLower(parsed.Host)
+
+	item, err := json.Marshal(Lease{URL: normalized, Depth: depth})
+	if err != nil {
+		return false, err
+	}
+
+	keys := []string{f.prefix + "seen", f.prefix + "queue:" + host, f.prefix + "hosts", f.prefix + "next"}
+	added, err := pushScript.Run(ctx, f.client, keys, normalized, string(item), host, nowMillis(), f.config.SeenTTL.Milliseconds()).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to push URL to frontier: %w", err)
+	}
+	return added == 1, nil
+}
+
+// Lease hands out the next URL whose host may be fetched now, or nil if none is ready.
+// Leases that expired because their worker died are requeued first.
+func (f *RedisFrontier) Lease(ctx context.Context) (*Lease, error) {
+	keys := []string{f.prefix + "hosts", f.prefix + "leases", f.prefix + "lease-data", f.prefix + "next", f.prefix + "lease-counter"}
+	result, err := leaseScript.Run(ctx, f.client, keys, f.prefix, f.config.WorkerID, nowMillis(),
+		f.config.LeaseTTL.Milliseconds(), f.config.HostDelay.Milliseconds()).StringSlice()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease URL from frontier: %w", err)
+	}
+
+	var lease Lease
+	if err := json.Unmarshal([]byte(result[1]), &lease); err != nil {
+		return nil, err
+	}
+	lease.ID = result[0]
+	return &lease, nil
+}
+
+// Complete releases a lease once its URL has been crawled.
+func (f *RedisFrontier) Complete(ctx context.Context, lease *Lease) error {
+	pipe := f.client.TxPipeline()
+	pipe.ZRem(ctx, f.prefix+"leases", lease.ID)
+	pipe.HDel(ctx, f.prefix+"lease-data", lease.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Idle reports whether the frontier has neither queued nor leased URLs.
+func (f *RedisFrontier) Idle(ctx context.Context) (bool, error) {
+	pipe := f.client.Pipeline()
+	hosts := pipe.ZCard(ctx, f.prefix+"hosts")
+	leases := pipe.ZCard(ctx, f.prefix+"leases")
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return hosts.Val() == 0 && leases.Val() == 0, nil
+}
+
+// Reset deletes the visited set, queues and leases of the job, so it is crawled again
+// from its seeds. It must not run while workers are crawling the job.
+func (f *RedisFrontier) Reset(ctx context.Context) error {
+	var keys []string
+	iter := f.client.Scan(ctx, 0, f.prefix+"*", 0).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return fmt.Errorf("failed to list frontier keys: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return f.client.Del(ctx, keys...).Err()
+}
+
+// CrawlDistributed crawls URLs leased from a shared frontier with the crawler's
+// concurrency until the frontier is idle or the context is cancelled.
+func (c *Crawler) CrawlDistributed(ctx context.Context, f *RedisFrontier) error {
+	workers := c.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			errs <- c.runWorker(ctx, f)
+		}()
+	}
+
+	var firstErr error
+	for i := 0; i < workers; i++ {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// runWorker leases and crawls URLs until the frontier is idle.
+func (c *Crawler) runWorker(ctx context.Context, f *RedisFrontier) error {
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		lease, err := f.Lease(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Wait for a host to become ready, or stop once every worker is done
+		if lease == nil {
+			idle, err := f.Idle(ctx)
+			if err != nil {
+				return err
+			}
+			if idle {
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+
+		doc, err := c.fetchDocument(lease.URL)
+		if err == nil {
+			c.addDocument(doc)
+
+			// Share the links found on the page with the other workers
+			if lease.Depth < c.maxDepth {
+				for _, link := range doc.Links {
+					if c.filterDomain != "" && !strings.Contains(link, c.filterDomain) {
+						continue
+					}
+					if _, ok := c.frontier.admit(link); !ok {
+						continue
+					}
+					if _, err := f.Push(ctx, link, lease.Depth+1); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if err := f.Complete(ctx, lease); err != nil {
+			return err
+		}
+	}
+}
+
+// nowMillis returns the current time in milliseconds.
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
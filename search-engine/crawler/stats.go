@@ -0,0 +1,313 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Constants for failure classes
+const (
+	ErrorClassStatus     = "status"
+	ErrorClassTimeout    = "timeout"
+	ErrorClassDNS        = "dns"
+	ErrorClassTLS        = "tls"
+	ErrorClassConnection = "connection"
+	ErrorClassParse      = "parse"
+	ErrorClassOther      = "other"
+)
+
+// latencyBuckets are the upper bounds of the latency histogram buckets in milliseconds.
+var latencyBuckets = []int64{50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// StatusError is returned when a page responds with an unexpected HTTP status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to fetch URL %s: status code %d", e.URL, e.StatusCode)
+}
+
+// ParseError is returned when a fetched page cannot be parsed.
+type ParseError struct {
+	URL string
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse URL %s: %v", e.URL, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Stats is a snapshot of the crawl counters.
+type Stats struct {
+	StartedAt        time.Time            `json:"startedAt"`
+	ElapsedSeconds   float64              `json:"elapsedSeconds"`
+	Queued           int64                `json:"queued"`
+	InFlight         int64                `json:"inFlight"`
+	Fetched          int64                `json:"fetched"`
+	Failed           int64                `json:"failed"`
+	FailuresByStatus map[int]int64        `json:"failuresByStatus"`
+	FailuresByClass  map[string]int64     `json:"failuresByClass"`
+	Bytes            int64                `json:"bytes"`
+	Hosts            map[string]HostStats `json:"hosts"`
+	Latency          LatencyHistogram     `json:"latency"`
+}
+
+// HostStats holds the counters of a single host.
+type HostStats struct {
+	Fetched        int64   `json:"fetched"`
+	Failed         int64   `json:"failed"`
+	Bytes          int64   `json:"bytes"`
+	PagesPerSecond float64 `json:"pagesPerSecond"`
+}
+
+// LatencyHistogram counts fetch latencies in cumulative-free buckets.
+type LatencyHistogram struct {
+	Buckets []LatencyBucket `json:"buckets"`
+	Count   int64           `json:"count"`
+	SumMs   int64           `json:"sumMs"`
+}
+
+// LatencyBucket counts the fetches that took at most LeMs milliseconds
+// (and more than the previous bucket). The last bucket has LeMs -1 and is unbounded.
+type LatencyBucket struct {
+	LeMs  int64 `json:"leMs"`
+	Count int64 `json:"count"`
+}
+
+// crawlStats maintains the crawl counters.
+type crawlStats struct {
+	mutex     sync.Mutex
+	startedAt time.Time
+	stats     Stats
+	latency   []int64
+}
+
+// newCrawlStats creates a new instance of crawlStats.
+func newCrawlStats() *crawlStats {
+	return &crawlStats{
+		startedAt: time.Now(),
+		latency:   make([]int64, len(latencyBuckets)+1),
+		stats: Stats{
+			FailuresByStatus: make(map[int]int64),
+			FailuresByClass:  make(map[string]int64),
+			Hosts:            make(map[string]HostStats),
+		},
+	}
+}
+
+// enqueue records a URL waiting to be crawled.
+func (s *crawlStats) enqueue() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stats.Queued++
+}
+
+// dequeue records a queued URL being picked up.
+func (s *crawlStats) dequeue() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stats.Queued--
+}
+
+// start records the start of a fetch.
+func (s *crawlStats) start() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stats.InFlight++
+	return time.Now()
+}
+
+// finish records the outcome of a fetch started at the given time.
+func (s *crawlStats) finish(host string, started time.Time, bytes int64, err error) {
+	elapsed := time.Since(started).Milliseconds()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.stats.InFlight--
+	s.stats.Bytes += bytes
+
+	hostStats := s.stats.Hosts[host]
+	hostStats.Bytes += bytes
+
+	if err != nil {
+		s.stats.Failed++
+		hostStats.Failed++
+
+		class := ClassifyError(err)
+		s.stats.FailuresByClass[class]++
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			s.stats.FailuresByStatus[statusErr.StatusCode]++
+		}
+	} else {
+		s.stats.Fetched++
+		hostStats.Fetched++
+	}
+	s.stats.Hosts[host] = hostStats
+
+	// Record the latency in the first bucket that fits
+	bucket := sort.Search(len(latencyBuckets), func(i int) bool {
+		return elapsed <= latencyBuckets[i]
+	})
+	s.latency[bucket]++
+	s.stats.Latency.Count++
+	s.stats.Latency.SumMs += elapsed
+}
+
+// snapshot returns a copy of the counters.
+func (s *crawlStats) snapshot() Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	elapsed := time.Since(s.startedAt).Seconds()
+
+	stats := s.stats
+	stats.StartedAt = s.startedAt
+	stats.ElapsedSeconds = elapsed
+
+	stats.FailuresByStatus = make(map[int]int64, len(s.stats.FailuresByStatus))
+	for status, count := range s.stats.FailuresByStatus {
+		stats.FailuresByStatus[status] = count
+	}
+	stats.FailuresByClass = make(map[string]int64, len(s.stats.FailuresByClass))
+	for class, count := range s.stats.FailuresByClass {
+		stats.FailuresByClass[class] = count
+	}
+
+	stats.Hosts = make(map[string]HostStats, len(s.stats.Hosts))
+	for host, hostStats := range s.stats.Hosts {
+		if elapsed > 0 {
+			hostStats.PagesPerSecond = float64(hostStats.Fetched) / elapsed
+		}
+		stats.Hosts[host] = hostStats
+	}
+
+	stats.Latency.Buckets = make([]LatencyBucket, len(s.latency))
+	for i, count := range s.latency {
+		le := int64(-1)
+		if i < len(latencyBuckets) {
+			le = latencyBuckets[i]
+		}
+		stats.Latency.Buckets[i] = LatencyBucket{LeMs: le, Count: count}
+	}
+
+	return stats
+}
+
+// ClassifyError returns the failure class of a fetch error.
+func ClassifyError(err error) string {
+	var statusErr *StatusError
+	var parseErr *ParseError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var opErr *net.OpError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var certInvalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var recordHeaderErr tls.RecordHeaderError
+
+	switch {
+	case errors.As(err, &statusErr):
+		return ErrorClassStatus
+	case errors.As(err, &parseErr):
+		return ErrorClassParse
+	case errors.As(err, &dnsErr):
+		return ErrorClassDNS
+	case errors.As(err, &unknownAuthorityErr), errors.As(err, &certInvalidErr),
+		errors.As(err, &hostnameErr), errors.As(err, &recordHeaderErr):
+		return ErrorClassTLS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	case errors.As(err, &opErr):
+		return ErrorClassConnection
+	default:
+		return ErrorClassOther
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// Stats returns a snapshot of the crawl counters.
+func (c *Crawler) Stats() Stats {
+	return c.stats.snapshot()
+}
+
+// LogProgress logs the crawl counters on the given interval until the context is cancelled.
+func (c *Crawler) LogProgress(ctx context.Context, log logrus.FieldLogger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := c.Stats()
+			log.WithFields(logrus.Fields{
+				"queued":   stats.Queued,
+				"inFlight": stats.InFlight,
+				"fetched":  stats.Fetched,
+				"failed":   stats.Failed,
+				"bytes":    stats.Bytes,
+				"hosts":    len(stats.Hosts),
+				"elapsed":  fmt.Sprintf("%.0fs", stats.ElapsedSeconds),
+			}).Info("Crawl progress")
+		}
+	}
+}
+
+// Report is the final crawl report.
+type Report struct {
+	Stats
+	Traps []Trap `json:"traps"`
+}
+
+// Report returns the crawl counters together with the detected crawler traps.
+func (c *Crawler) Report() Report {
+	return Report{
+		Stats: c.Stats(),
+		Traps: c.frontier.detectedTraps(),
+	}
+}
+
+// WriteReport writes the final crawl report as JSON to the given path.
+func (c *Crawler) WriteReport(path string) error {
+	data, err := json.MarshalIndent(c.Report(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
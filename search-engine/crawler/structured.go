@@ -0,0 +1,247 @@
+package crawler
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Constants for the typed fields extracted from structured data
+const (
+	FieldType          = "type"
+	FieldName          = "name"
+	FieldPrice         = "price"
+	FieldCurrency      = "currency"
+	FieldRating        = "rating"
+	FieldRatingCount   = "ratingCount"
+	FieldAvailability  = "availability"
+	FieldDatePublished = "datePublished"
+	FieldDateModified  = "dateModified"
+)
+
+// structuredProperties maps schema.org property names to document fields.
+var structuredProperties = map[string]string{
+	"name":          FieldName,
+	"price":         FieldPrice,
+	"lowPrice":      FieldPrice,
+	"priceCurrency": FieldCurrency,
+	"ratingValue":   FieldRating,
+	"reviewCount":   FieldRatingCount,
+	"ratingCount":   FieldRatingCount,
+	"availability":  FieldAvailability,
+	"datePublished": FieldDatePublished,
+	"releaseDate":   FieldDatePublished,
+	"dateModified":  FieldDateModified,
+}
+
+// nestedProperties lists the schema.org properties whose nested items are flattened into the product.
+var nestedProperties = map[string]bool{
+	"offers":          true,
+	"aggregateRating": true,
+}
+
+// structuredDateLayouts lists the accepted schema.org date formats.
+var structuredDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// ExtractStructuredData extracts schema.org Product data embedded as JSON-LD,
+// Microdata or RDFa into typed fields. Earlier formats win when several define a field.
+func ExtractStructuredData(doc *goquery.Document) map[string]interface{} {
+	fields := make(map[string]interface{})
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err == nil {
+			extractJSONLD(data, fields)
+		}
+	})
+
+	doc.Find("[itemscope][itemtype]").Each(func(i int, s *goquery.Selection) {
+		if itemType, _ := s.Attr("itemtype"); isProductType(itemType) {
+			extractScoped(s, "itemscope", "itemprop", fields)
+			setField(fields, FieldType, "Product")
+		}
+	})
+
+	doc.Find("[typeof]").Each(func(i int, s *goquery.Selection) {
+		if itemType, _ := s.Attr("typeof"); isProductType(itemType) {
+			extractScoped(s, "typeof", "property", fields)
+			setField(fields, FieldType, "Product")
+		}
+	})
+
+	return fields
+}
+
+// extractJSONLD walks a decoded JSON-LD value looking for Product items.
+func extractJSONLD(data interface{}, fields map[string]interface{}) {
+	switch value := data.(type) {
+	case []interface{}:
+		for _, item := range value {
+			extractJSONLD(item, fields)
+		}
+	case map[string]interface{}:
+		if graph, ok := value["@graph"]; ok {
+			extractJSONLD(graph, fields)
+		}
+		if !isProductType(jsonLDType(value["@type"])) {
+			return
+		}
+		setField(fields, FieldType, "Product")
+		extractJSONLDItem(value, fields)
+	}
+}
+
+// extractJSONLDItem copies the known properties of a JSON-LD item and its nested offers and ratings.
+func extractJSONLDItem(item map[string]interface{}, fields map[string]interface{}) {
+	for property, value := range item {
+		if nestedProperties[property] {
+			// Offers may be a single item or a list
+			switch nested := value.(type) {
+			case map[string]interface{}:
+				extractJSONLDItem(nested, fields)
+			case []interface{}:
+				for _, entry := range nested {
+					if nestedItem, ok := entry.(map[string]interface{}); ok {
+						extractJSONLDItem(nestedItem, fields)
+					}
+				}
+			}
+			continue
+		}
+
+		if field, ok := structuredProperties[property]; ok {
+			switch v := value.(type) {
+			case string:
+				setField(fields, field, v)
+			case float64:
+				setField(fields, field, strconv.FormatFloat(v, 'f', -1, 64))
+			}
+		}
+	}
+}
+
+// extractScoped copies the properties of a Microdata (itemscope/itemprop) or
+// RDFa (typeof/property) item, flattening nested offers and ratings.
+func extractScoped(item *goquery.Selection, scopeAttr, propAttr string, fields map[string]interface{}) {
+	item.Find("[" + propAttr + "]").Each(func(i int, s *goquery.Selection) {
+		// Only accept properties of the item itself or of its nested offers and ratings
+		scope := s.Parent().Closest("[" + scopeAttr + "]")
+		if !scope.IsSelection(item) {
+			scopeProp, _ := scope.Attr(propAttr)
+			if !nestedProperties[propertyName(scopeProp)] || !scope.Parent().Closest("["+scopeAttr+"]").IsSelection(item) {
+				return
+			}
+		}
+
+		prop, _ := s.Attr(propAttr)
+		for _, name := range strings.Fields(prop) {
+			if field, ok := structuredProperties[propertyName(name)]; ok {
+				setField(fields, field, scopedValue(s))
+			}
+		}
+	})
+}
+
+// scopedValue returns the value of a Microdata or RDFa property element.
+func scopedValue(s *goquery.Selection) string {
+	for _, attr := range []string{"content", "datetime", "href", "resource", "src"} {
+		if value, ok := s.Attr(attr); ok {
+			return value
+		}
+	}
+	return strings.TrimSpace(s.Text())
+}
+
+// setField converts a raw value to the field's type and stores it unless the field is already set.
+func setField(fields map[string]interface{}, field, raw string) {
+	raw = strings.TrimSpace(raw)
+	if _, ok := fields[field]; ok || raw == "" {
+		return
+	}
+
+	switch field {
+	case FieldPrice, FieldRating:
+		if value, ok := parseNumber(raw); ok {
+			fields[field] = value
+		}
+	case FieldRatingCount:
+		if value, err := strconv.Atoi(raw); err == nil {
+			fields[field] = value
+		}
+	case FieldDatePublished, FieldDateModified:
+		if value, ok := parseDate(raw); ok {
+			fields[field] = value
+		}
+	case FieldAvailability:
+		// "https://schema.org/InStock" becomes "InStock"
+		fields[field] = raw[strings.LastIndex(raw, "/")+1:]
+	default:
+		fields[field] = raw
+	}
+}
+
+// parseNumber parses a number, tolerating currency symbols and thousands separators, e.g. "$1,299.00".
+func parseNumber(raw string) (float64, bool) {
+	cleaned := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+		return -1
+	}, raw)
+
+	value, err := strconv.ParseFloat(cleaned, 64)
+	return value, err == nil
+}
+
+// parseDate parses a date in any of the accepted schema.org formats.
+func parseDate(raw string) (time.Time, bool) {
+	for _, layout := range structuredDateLayouts {
+		if value, err := time.Parse(layout, raw); err == nil {
+			return value, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// isProductType reports whether a schema.org type (list) names a Product.
+func isProductType(itemType string) bool {
+	for _, t := range strings.Fields(itemType) {
+		if propertyName(t) == "Product" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonLDType returns the @type of a JSON-LD item as a space-separated string.
+func jsonLDType(value interface{}) string {
+	switch t := value.(type) {
+	case string:
+		return t
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return strings.Join(types, " ")
+	}
+	return ""
+}
+
+// propertyName strips the vocabulary prefix or URL from a property or type name,
+// e.g. "schema:price" and "http://schema.org/price" become "price".
+func propertyName(name string) string {
+	if i := strings.LastIndexAny(name, ":/#"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
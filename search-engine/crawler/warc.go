@@ -0,0 +1,106 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadWARC reads the response records of a WARC file (optionally gzip-compressed)
+// and passes every successfully parsed HTML page to fn.
+func ReadWARC(r io.Reader, fn func(*Document) error) error {
+	br := bufio.NewReader(r)
+
+	// Transparently decompress .warc.gz files
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gzr.Close()
+		br = bufio.NewReader(gzr)
+	}
+
+	tp := textproto.NewReader(br)
+	for {
+		// Find the version line of the next record, skipping record separators
+		line, err := tp.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "WARC/") {
+			return fmt.Errorf("invalid WARC record version line: %q", line)
+		}
+
+		// Read the record headers
+		header, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return fmt.Errorf("failed to read WARC record header: %w", err)
+		}
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid WARC Content-Length: %w", err)
+		}
+		block := io.LimitReader(br, length)
+
+		// Only HTTP responses carry pages worth indexing
+		if header.Get("WARC-Type") == "response" && strings.HasPrefix(header.Get("Content-Type"), "application/http") {
+			doc, err := parseWARCResponse(header, block)
+			if err != nil {
+				return err
+			}
+			if doc != nil {
+				if err := fn(doc); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Skip whatever is left of the record block
+		if _, err := io.Copy(io.Discard, block); err != nil {
+			return err
+		}
+	}
+}
+
+// parseWARCResponse parses the HTTP response stored in a WARC response record.
+// It returns nil if the response is not a successful HTML page.
+func parseWARCResponse(header textproto.MIMEHeader, block io.Reader) (*Document, error) {
+	res, err := http.ReadResponse(bufio.NewReader(block), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTTP response for %s: %w", header.Get("WARC-Target-URI"), err)
+	}
+	defer res.Body.Close()
+
+	// Check the HTTP response status code and content type
+	if res.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	if contentType := res.Header.Get("Content-Type"); contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, nil
+	}
+
+	doc, err := ParseDocument(header.Get("WARC-Target-URI"), res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the capture date as the document date
+	if date, err := time.Parse(time.RFC3339, header.Get("WARC-Date")); err == nil {
+		doc.Date = date
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,213 @@
+package indexer
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// Constants for the cache types
+const (
+	CacheNone   = "none"
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+	CacheTiered = "tiered"
+)
+
+// Cache stores encoded query results.
+type Cache interface {
+	// Get returns the value of a key and reports whether it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set stores the value of a key for the given lifetime.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// CacheConfig configures the query result cache.
+type CacheConfig struct {
+	Type        string        `yaml:"type"`        // none, memory, redis or tiered (memory in front of Redis)
+	Namespace   string        `yaml:"namespace"`   // prefix of every cache key, to share a Redis between indexes
+	TTL         time.Duration `yaml:"ttl"`         // lifetime of cached results
+	NegativeTTL time.Duration `yaml:"negativeTTL"` // lifetime of cached empty results
+	MaxBytes    int           `yaml:"maxBytes"`    // size bound of the in-process cache
+}
+
+// DefaultCacheConfig returns a config caching results in process for an hour and empty results for a minute.
+func DefaultCacheConfig() CacheConfig {
+	return CacheConfig{
+		Type:        CacheMemory,
+		Namespace:   "search",
+		TTL:         time.Hour,
+		NegativeTTL: time.Minute,
+		MaxBytes:    64 << 20,
+	}
+}
+
+// NewCache creates the cache selected by the config. The Redis client is only used
+// by the redis and tiered types. A nil Cache disables caching.
+func NewCache(config CacheConfig, redisClient *redis.Client) (Cache, error) {
+	switch config.Type {
+	case CacheNone:
+		return nil, nil
+	case CacheMemory, "":
+		return NewLRUCache(config.MaxBytes), nil
+	case CacheRedis, CacheTiered:
+		if redisClient == nil {
+			return nil, fmt.Errorf("cache type %q needs a Redis client", config.Type)
+		}
+		if config.Type == CacheRedis {
+			return NewRedisCache(redisClient), nil
+		}
+		return NewTieredCache(NewLRUCache(config.MaxBytes), NewRedisCache(redisClient), config.TTL), nil
+	default:
+		return nil, fmt.Errorf("unknown cache type %q", config.Type)
+	}
+}
+
+// RedisCache is a Cache stored in Redis.
+type RedisCache struct {
+	client *redis.Client
+}
+
+// NewRedisCache creates a new instance of RedisCache.
+func NewRedisCache(client *redis.Client) *RedisCache {
+	return &RedisCache{client: client}
+}
+
+// Get returns the value of a key and reports whether it was found.
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set stores the value of a key for the given lifetime.
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+// TieredCache is a fast near cache, usually in process, in front of a shared far cache.
+type TieredCache struct {
+	near Cache
+	far  Cache
+	ttl  time.Duration
+}
+
+// NewTieredCache creates a new instance of TieredCache.
+// Values found in the far cache are kept in the near cache for ttl.
+func NewTieredCache(near, far Cache, ttl time.Duration) *TieredCache {
+	return &TieredCache{near: near, far: far, ttl: ttl}
+}
+
+// Get returns the value of a key from the near cache, falling back to the far cache.
+func (c *TieredCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if value, ok, err := c.near.Get(ctx, key); err == nil && ok {
+		return value, true, nil
+	}
+
+	value, ok, err := c.far.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return value, true, c.near.Set(ctx, key, value, c.ttl)
+}
+
+// Set stores the value of a key in both caches.
+func (c *TieredCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if err := c.near.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	return c.far.Set(ctx, key, value, ttl)
+}
+
+// CacheStats counts the lookups of the query result cache.
+type CacheStats struct {
+	Hits         int64   `json:"hits"`
+	NegativeHits int64   `json:"negativeHits"` // hits on cached empty results, included in Hits
+	Misses       int64   `json:"misses"`
+	Errors       int64   `json:"errors"` // failed cache reads and writes
+	HitRatio     float64 `json:"hitRatio"`
+}
+
+// cacheCounters holds the live counters behind CacheStats.
+type cacheCounters struct {
+	hits         atomic.Int64
+	negativeHits atomic.Int64
+	misses       atomic.Int64
+	errors       atomic.Int64
+}
+
+// stats returns a snapshot of the counters.
+func (c *cacheCounters) stats() CacheStats {
+	stats := CacheStats{
+		Hits:         c.hits.Load(),
+		NegativeHits: c.negativeHits.Load(),
+		Misses:       c.misses.Load(),
+		Errors:       c.errors.Load(),
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// CacheStats returns the hit and miss counts of the query result cache.
+func (i *Indexer) CacheStats() CacheStats {
+	return i.cacheCounters.stats()
+}
+
+// SetCacheConfig sets the namespace and lifetimes of cached query results.
+func (i *Indexer) SetCacheConfig(config CacheConfig) {
+	i.cacheConfig = config
+}
+
+// Generation returns the index generation, which changes with every write that changes query results.
+func (i *Indexer) Generation() (uint64, error) {
+	var generation uint64
+	err := i.db.View(func(r storage.Reader) error {
+		var err error
+		generation, err = readGeneration(r)
+		return err
+	})
+	return generation, err
+}
+
+// cacheKey returns the cache key of a word's results in an index generation.
+// Bumping the generation invalidates every key of the previous one.
+func (i *Indexer) cacheKey(generation uint64, word string) string {
+	if i.cacheScope != "" {
+		return fmt.Sprintf("%s:%s:%d:query:%s", i.cacheConfig.Namespace, i.cacheScope, generation, word)
+	}
+	return fmt.Sprintf("%s:%d:query:%s", i.cacheConfig.Namespace, generation, word)
+}
+
+// generationKey is the key of the index generation.
+var generationKey = []byte(metaPrefix + "generation")
+
+// readGeneration returns the index generation.
+func readGeneration(r storage.Reader) (uint64, error) {
+	value, err := r.Get(generationKey)
+	if err != nil || len(value) != 8 {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(value), nil
+}
+
+// bumpGeneration starts a new index generation within a batch.
+func bumpGeneration(w storage.Writer) error {
+	generation, err := readGeneration(w)
+	if err != nil {
+		return err
+	}
+	return w.Put(generationKey, binary.BigEndian.AppendUint64(nil, generation+1))
+}
@@ -0,0 +1,151 @@
+package indexer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/analyzer"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+)
+
+// DefaultCollection names the index kept at the root of a store, which every
+// store has and which holds the documents indexed before collections existed.
+const DefaultCollection = "default"
+
+var (
+	// ErrCollectionExists is returned when creating a collection that already exists.
+	ErrCollectionExists = errors.New("collection already exists")
+
+	// ErrCollectionNotFound is returned for a collection that does not exist.
+	ErrCollectionNotFound = errors.New("collection not found")
+)
+
+// collectionName matches valid collection names.
+var collectionName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// CollectionConfig describes a named collection, a separate index with its own
+// analyzer settings, stored fields and schema kept in its own namespace of the store.
+type CollectionConfig struct {
+	Name     string          `json:"name" yaml:"name"`
+	Analyzer analyzer.Config `json:"analyzer" yaml:"analyzer"`
+	DocStore DocStoreConfig  `json:"docStore" yaml:"docStore"`
+	Schema   Schema          `json:"schema" yaml:"schema"`
+	Created  time.Time       `json:"created" yaml:"-"`
+}
+
+// Collections creates, lists and drops the named collections of a store.
+type Collections struct {
+	db storage.Store
+}
+
+// NewCollections creates a new instance of Collections.
+func NewCollections(db storage.Store) *Collections {
+	return &Collections{db: db}
+}
+
+// Create registers a new collection. A config without stored fields keeps the default ones.
+func (c *Collections) Create(config CollectionConfig) (CollectionConfig, error) {
+	if config.Name == DefaultCollection || !collectionName.MatchString(config.Name) {
+		return config, fmt.Errorf("invalid collection name %q", config.Name)
+	}
+	if err := config.Schema.Validate(); err != nil {
+		return config, fmt.Errorf("invalid schema of collection %s: %w", config.Name, err)
+	}
+	if len(config.DocStore.StoredFields) == 0 {
+		config.DocStore = DefaultDocStoreConfig()
+	}
+	config.Created = time.Now().UTC()
+
+	value, err := json.Marshal(config)
+	if err != nil {
+		return config, err
+	}
+	err = c.db.Update(func(w storage.Writer) error {
+		old, err := w.Get(key(collectionPrefix, []byte(config.Name)))
+		if err != nil {
+			return err
+		}
+		if old != nil {
+			return fmt.Errorf("%w: %s", ErrCollectionExists, config.Name)
+		}
+		return w.Put(key(collectionPrefix, []byte(config.Name)), value)
+	})
+	return config, err
+}
+
+// Get returns the config of a collection.
+func (c *Collections) Get(name string) (CollectionConfig, error) {
+	var config CollectionConfig
+	err := c.db.View(func(r storage.Reader) error {
+		value, err := r.Get(key(collectionPrefix, []byte(name)))
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			return fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+		}
+		return json.Unmarshal(value, &config)
+	})
+	return config, err
+}
+
+// List returns the configs of every collection, ordered by name.
+func (c *Collections) List() ([]CollectionConfig, error) {
+	var configs []CollectionConfig
+	err := c.db.View(func(r storage.Reader) error {
+		return r.ScanPrefix([]byte(collectionPrefix), func(k, value []byte) error {
+			var config CollectionConfig
+			if err := json.Unmarshal(value, &config); err != nil {
+				return fmt.Errorf("failed to read collection %s: %w", k[len(collectionPrefix):], err)
+			}
+			configs = append(configs, config)
+			return nil
+		})
+	})
+	sort.Slice(configs, func(a, b int) bool { return configs[a].Name < configs[b].Name })
+	return configs, err
+}
+
+// Drop removes a collection and all of its data in one batch.
+func (c *Collections) Drop(name string) error {
+	return c.db.Update(func(w storage.Writer) error {
+		k := key(collectionPrefix, []byte(name))
+		value, err := w.Get(k)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			return fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+		}
+		if err := deletePrefix(w, collectionData(name)); err != nil {
+			return err
+		}
+		return w.Delete(k)
+	})
+}
+
+// Store returns the namespace of the store holding the data of a collection.
+func (c *Collections) Store(config CollectionConfig) storage.Store {
+	return storage.Namespace(c.db, string(collectionData(config.Name)))
+}
+
+// collectionData returns the prefix of the data of a collection.
+func collectionData(name string) []byte {
+	return key(collectionDataPrefix, []byte(name), []byte("/"))
+}
+
+// SetCollection configures the indexer for a collection: its analyzer, its stored
+// fields, its schema and a cache namespace of its own. The indexer's store must be the
+// collection's store.
+func (i *Indexer) SetCollection(config CollectionConfig) {
+	i.SetAnalyzer(analyzer.New(config.Analyzer))
+	i.SetDocStoreConfig(config.DocStore)
+	i.SetSchema(config.Schema)
+
+	// A collection dropped and created again must not see the results cached before
+	i.cacheScope = fmt.Sprintf("%s@%d", config.Name, config.Created.UnixNano())
+}
@@ -6,23 +6,11 @@ import (
 	"fmt"
 	"path/filepath"
 
-	"github.com/boltdb/bolt"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
 )
 
-// BoltDB represents a BoltDB instance.
-type BoltDB struct {
-	DB *bolt.DB
-}
-
-// Database is an interface that represents the database functionality.
-type Database interface {
-	Update(fn func(*bolt.Tx) error) error
-	View(fn func(*bolt.Tx) error) error
-	Close() error
-}
-
-// NewBoltDB creates a new instance of BoltDB and opens the specified database file.
-func NewBoltDB(path string) (*BoltDB, func() error, error) {
+// NewBoltDB opens the specified BoltDB file, relative to the project root, as a store.
+func NewBoltDB(path string) (*storage.BoltStore, func() error, error) {
 	// Get the project root directory
 	projectRoot, err := FindProjectRoot()
 	if err != nil {
@@ -32,32 +20,31 @@ func NewBoltDB(path string) (*BoltDB, func() error, error) {
 	// Construct the file path relative to the project root directory
 	filePath := filepath.Join(projectRoot, path)
 
-	// Create a BoltDB instance using bolt.Open.
-	db, err := bolt.Open(filePath, 0666, &bolt.Options{})
+	db, err := storage.OpenBolt(filePath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create BoltDB: %w", err)
 	}
 
-	boltDB := &BoltDB{
-		DB: db,
-	}
-
 	// Define the cleanup function to close the database.
 	cleanup := func() error {
-		return boltDB.DB.Close()
+		return db.Close()
 	}
 
-	return boltDB, cleanup, nil
+	return db, cleanup, nil
 }
 
-func (db *BoltDB) Update(fn func(*bolt.Tx) error) error {
-	return db.DB.Update(fn)
-}
+// NewStore opens the store of a storage engine at the specified path, relative to the project root.
+func NewStore(engine, path string) (storage.Store, func() error, error) {
+	// Get the project root directory
+	projectRoot, err := FindProjectRoot()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find project root: %w", err)
+	}
 
-func (db *BoltDB) View(fn func(*bolt.Tx) error) error {
-	return db.DB.View(fn)
-}
+	db, err := storage.Open(engine, filepath.Join(projectRoot, path))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s store: %w", engine, err)
+	}
 
-func (db *BoltDB) Close() error {
-	return db.DB.Close()
+	return db, db.Close, nil
 }
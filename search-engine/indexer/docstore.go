@@ -0,0 +1,282 @@
+package indexer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+)
+
+// Constants for the fields a DocStore can keep
+const (
+	StoredTitle       = "title"
+	StoredURL         = "url"
+	StoredDescription = "description"
+	StoredDate        = "date"
+	StoredExcerpt     = "excerpt"
+	StoredTypedFields = "fields"
+)
+
+// Document is a document handed to the indexer.
+type Document struct {
+	URL         string
+	Title       string
+	Description string
+	Body        string
+	Date        time.Time
+	Fields      map[string]interface{}
+}
+
+// StoredDocument is the display copy of a document kept in the DocStore.
+type StoredDocument struct {
+	ID          uint64                 `json:"id"`
+	URL         string                 `json:"url"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Date        time.Time              `json:"date,omitempty"`
+	Excerpt     string                 `json:"excerpt,omitempty"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+}
+
+// DocStoreConfig selects which fields the DocStore keeps.
+type DocStoreConfig struct {
+	StoredFields  []string `yaml:"storedFields"`
+	ExcerptLength int      `yaml:"excerptLength"` // characters of body text kept as the excerpt
+}
+
+// DefaultDocStoreConfig returns a config storing every field with a 300 character excerpt.
+func DefaultDocStoreConfig() DocStoreConfig {
+	return DocStoreConfig{
+		StoredFields:  []string{StoredTitle, StoredURL, StoredDescription, StoredDate, StoredExcerpt, StoredTypedFields},
+		ExcerptLength: 300,
+	}
+}
+
+// DocStore assigns compact numeric document IDs, maps URLs to IDs and back,
+// and keeps a compressed copy of the stored fields of every document.
+type DocStore struct {
+	db     storage.Store
+	config DocStoreConfig
+	stored map[string]bool
+}
+
+// NewDocStore creates a new instance of DocStore.
+func NewDocStore(db storage.Store, config DocStoreConfig) *DocStore {
+	stored := make(map[string]bool)
+	for _, field := range config.StoredFields {
+		stored[field] = true
+	}
+
+	return &DocStore{
+		db:     db,
+		config: config,
+		stored: stored,
+	}
+}
+
+// Put stores documents, reusing the ID of a URL that was stored before,
+// and returns their IDs in order.
+func (s *DocStore) Put(docs []Document) ([]uint64, error) {
+	ids := make([]uint64, len(docs))
+
+	// Write all documents in one batch
+	err := s.db.Update(func(w storage.Writer) error {
+		for i, doc := range docs {
+			id, err := s.put(w, doc)
+			if err != nil {
+				return err
+			}
+			ids[i] = id
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// put stores a single document within a batch.
+func (s *DocStore) put(w storage.Writer, doc Document) (uint64, error) {
+	// Reuse the ID of a known URL, or assign the next one
+	id, err := assignDocID(w, doc.URL)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := compressJSON(s.displayCopy(id, doc))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode document %s: %w", doc.URL, err)
+	}
+	if err := w.Put(docKey(docPrefix, id), value); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// displayCopy builds the display copy of a document with the configured fields.
+func (s *DocStore) displayCopy(id uint64, doc Document) StoredDocument {
+	stored := StoredDocument{ID: id}
+	if s.stored[StoredURL] {
+		stored.URL = doc.URL
+	}
+	if s.stored[StoredTitle] {
+		stored.Title = doc.Title
+	}
+	if s.stored[StoredDescription] {
+		stored.Description = doc.Description
+	}
+	if s.stored[StoredDate] {
+		stored.Date = doc.Date
+	}
+	if s.stored[StoredExcerpt] {
+		stored.Excerpt = excerpt(doc.Body, s.config.ExcerptLength)
+	}
+	if s.stored[StoredTypedFields] && len(doc.Fields) > 0 {
+		stored.Fields = doc.Fields
+	}
+	return stored
+}
+
+// Get returns a stored document by ID, or nil if it does not exist.
+func (s *DocStore) Get(id uint64) (*StoredDocument, error) {
+	var doc *StoredDocument
+	err := s.db.View(func(r storage.Reader) error {
+		var err error
+		doc, err = ReadDocument(r, id)
+		return err
+	})
+	return doc, err
+}
+
+// GetByURL returns a stored document by URL, or nil if it does not exist.
+func (s *DocStore) GetByURL(url string) (*StoredDocument, error) {
+	var doc *StoredDocument
+	err := s.db.View(func(r storage.Reader) error {
+		var err error
+		doc, err = ReadDocumentByURL(r, url)
+		return err
+	})
+	return doc, err
+}
+
+// assignDocID returns the ID of a URL, assigning the next free ID to unknown URLs.
+func assignDocID(w storage.Writer, url string) (uint64, error) {
+	id, ok, err := LookupDocID(w, url)
+	if err != nil || ok {
+		return id, err
+	}
+
+	id, err = nextSequence(w, "doc")
+	if err != nil {
+		return 0, err
+	}
+	if err := w.Put(key(docIDPrefix, []byte(url)), EncodeDocID(id)); err != nil {
+		return 0, err
+	}
+	if err := w.Put(docKey(docURLPrefix, id), []byte(url)); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// LookupDocURL returns the URL of a document ID and reports whether it exists.
+func LookupDocURL(r storage.Reader, id uint64) (string, bool, error) {
+	value, err := r.Get(docKey(docURLPrefix, id))
+	if err != nil || value == nil {
+		return "", false, err
+	}
+	return string(value), true, nil
+}
+
+// LookupDocID returns the ID assigned to a URL and reports whether it exists.
+func LookupDocID(r storage.Reader, url string) (uint64, bool, error) {
+	value, err := r.Get(key(docIDPrefix, []byte(url)))
+	if err != nil || value == nil {
+		return 0, false, err
+	}
+	return DecodeDocID(value), true, nil
+}
+
+// ReadDocument reads a stored document by ID, or nil if it does not exist.
+func ReadDocument(r storage.Reader, id uint64) (*StoredDocument, error) {
+	value, err := r.Get(docKey(docPrefix, id))
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	var doc StoredDocument
+	if err := decompressJSON(value, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode document %d: %w", id, err)
+	}
+	return &doc, nil
+}
+
+// ReadDocumentByURL reads a stored document by URL, or nil if it does not exist.
+func ReadDocumentByURL(r storage.Reader, url string) (*StoredDocument, error) {
+	id, ok, err := LookupDocID(r, url)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return ReadDocument(r, id)
+}
+
+// EncodeDocID encodes a document ID as a sortable 8-byte key.
+func EncodeDocID(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// DecodeDocID decodes a key written by EncodeDocID.
+func DecodeDocID(key []byte) uint64 {
+	return binary.BigEndian.Uint64(key)
+}
+
+// compressJSON encodes a value as gzip-compressed JSON.
+func compressJSON(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gzw).Encode(value); err != nil {
+		gzw.Close()
+		return nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressJSON decodes gzip-compressed JSON into value.
+func decompressJSON(data []byte, value interface{}) error {
+	gzr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	raw, err := io.ReadAll(gzr)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, value)
+}
+
+// excerpt returns the first characters of the body text with whitespace collapsed.
+func excerpt(body string, length int) string {
+	text := strings.Join(strings.Fields(body), " ")
+	if length <= 0 || utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	return string([]rune(text)[:length])
+}
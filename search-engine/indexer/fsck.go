@@ -0,0 +1,736 @@
+package indexer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/codec"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+)
+
+// Constants for the kinds of inconsistencies found by Fsck
+const (
+	IssueCorruptPostings = "corrupt-postings" // a postings list does not decode
+	IssueCorruptDocument = "corrupt-document" // a stored document does not decode
+	IssueDanglingPosting = "dangling-posting" // a live posting refers to an unknown document
+	IssueDocumentMapping = "document-mapping" // the URL and ID mappings of a document disagree
+	IssueSegmentStats    = "segment-stats"    // the info of a segment does not match its postings
+	IssueDocumentTerms   = "document-terms"   // the recorded terms of a document do not match its postings
+	IssueDocumentLength  = "document-length"  // the recorded length of a document does not match its postings
+	IssueDocumentValues  = "document-values"  // the sortable and facetable values of a document do not decode
+	IssueSequence        = "sequence"         // an ID sequence is behind the IDs in use
+	IssueLegacyData      = "legacy-data"      // data awaiting Migrate
+)
+
+// FsckIssue is an inconsistency found in an index. An issue whose repair had to drop index
+// data is not repaired: its documents only match again once they are indexed again.
+type FsckIssue struct {
+	Kind     string `json:"kind"`
+	Detail   string `json:"detail"`
+	Repaired bool   `json:"repaired"`
+	Dropped  bool   `json:"dropped,omitempty"`
+}
+
+// FsckReport summarizes the check of an index.
+type FsckReport struct {
+	Segments  int         `json:"segments"`
+	Terms     int         `json:"terms"`
+	Postings  int         `json:"postings"`
+	Documents int         `json:"documents"`
+	Issues    []FsckIssue `json:"issues"`
+}
+
+// Unrepaired returns the number of issues left unrepaired.
+func (r *FsckReport) Unrepaired() int {
+	unrepaired := 0
+	for _, issue := range r.Issues {
+		if !issue.Repaired {
+			unrepaired++
+		}
+	}
+	return unrepaired
+}
+
+// Fsck checks that every postings list decodes, that live postings refer to known documents,
+// that the URL and ID mappings agree, that segment stats, document term lists and document
+// lengths match the postings, and that document values decode. With repair set, it rebuilds
+// the mappings from the document store and the stats, term lists and lengths from the
+// postings. Postings lists and values that cannot be read cannot be rebuilt, as the document
+// store does not keep the text of documents: they are dropped and reported as unrepaired,
+// and the documents they belonged to are re-indexed the next time they are indexed.
+func (i *Indexer) Fsck(repair bool) (*FsckReport, error) {
+	report := &FsckReport{}
+	check := func(r storage.Reader, w storage.Writer) error {
+		f := &fsck{r: r, w: w, report: report, schema: i.schema}
+		return f.run()
+	}
+
+	if repair {
+		err := i.db.Update(func(w storage.Writer) error {
+			return check(w, w)
+		})
+		return report, err
+	}
+	err := i.db.View(func(r storage.Reader) error {
+		return check(r, nil)
+	})
+	return report, err
+}
+
+// fsck holds the state of a single index check.
+type fsck struct {
+	r      storage.Reader
+	w      storage.Writer // nil unless repairing
+	report *FsckReport
+	schema Schema
+
+	urls     map[uint64]string            // document URLs by ID, after repairs
+	docTerms map[uint64]map[string]uint32 // frequencies of the terms of the live postings of every document
+	affected map[uint64]bool              // documents recording terms whose postings lists do not decode
+	repaired bool
+}
+
+// issue records an inconsistency, which fix repairs if the check repairs.
+func (f *fsck) issue(kind, detail string, fix func() error) error {
+	issue := FsckIssue{Kind: kind, Detail: detail}
+	if f.w != nil && fix != nil {
+		if err := fix(); err != nil {
+			return err
+		}
+		issue.Repaired = true
+		f.repaired = true
+	}
+	f.report.Issues = append(f.report.Issues, issue)
+	return nil
+}
+
+// drop records an inconsistency whose fix drops index data, which leaves it unrepaired.
+func (f *fsck) drop(kind, detail string, fix func() error) error {
+	issue := FsckIssue{Kind: kind, Detail: detail}
+	if f.w != nil {
+		if err := fix(); err != nil {
+			return err
+		}
+		issue.Dropped = true
+		f.repaired = true
+	}
+	f.report.Issues = append(f.report.Issues, issue)
+	return nil
+}
+
+// reindex makes the next indexing of documents re-index them even if they did not change,
+// and forgets their lengths, which no longer match their postings until then.
+func (f *fsck) reindex(ids []uint64) error {
+	for _, id := range ids {
+		if err := f.w.Delete(docKey(docChecksumPrefix, id)); err != nil {
+			return err
+		}
+		if err := f.w.Delete(docKey(docLengthPrefix, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// documentsWithTerm returns the known documents whose recorded terms include a term.
+func (f *fsck) documentsWithTerm(term string) ([]uint64, error) {
+	var ids []uint64
+	for _, id := range sortedIDs(f.urls) {
+		value, err := f.r.Get(docKey(docTermsPrefix, id))
+		if err != nil {
+			return nil, err
+		}
+		terms, err := decodeTerms(value)
+		if err != nil {
+			continue
+		}
+		for _, t := range terms {
+			if t == term {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+// formatIDs formats document IDs as a list.
+func formatIDs(ids []uint64) string {
+	if len(ids) == 0 {
+		return "none"
+	}
+	s := make([]string, len(ids))
+	for j, id := range ids {
+		s[j] = strconv.FormatUint(id, 10)
+	}
+	return strings.Join(s, ", ")
+}
+
+func (f *fsck) run() error {
+	var legacy int
+	err := f.r.ScanPrefix([]byte(legacyIndexPrefix), func(_, _ []byte) error {
+		legacy++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if legacy > 0 {
+		if err := f.issue(IssueLegacyData, fmt.Sprintf("%d legacy IndexBucket entries, run index migrate", legacy), nil); err != nil {
+			return err
+		}
+	}
+
+	if err := f.checkDocuments(); err != nil {
+		return err
+	}
+	if err := f.checkSegments(); err != nil {
+		return err
+	}
+	if err := f.checkDocTerms(); err != nil {
+		return err
+	}
+	if err := f.checkDocLengths(); err != nil {
+		return err
+	}
+	if err := f.checkDocValues(); err != nil {
+		return err
+	}
+
+	// Repairs change query results
+	if f.repaired {
+		return bumpGeneration(f.w)
+	}
+	return nil
+}
+
+// checkDocuments checks that the URL and ID mappings agree with each other and with the
+// document store, rebuilding them from the stored documents when repairing.
+func (f *fsck) checkDocuments() error {
+	f.urls = make(map[uint64]string)
+	err := f.r.ScanPrefix([]byte(docURLPrefix), func(k, value []byte) error {
+		f.urls[DecodeDocID(k[len(docURLPrefix):])] = string(value)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ids := make(map[string]uint64)
+	err = f.r.ScanPrefix([]byte(docIDPrefix), func(k, value []byte) error {
+		ids[string(k[len(docIDPrefix):])] = DecodeDocID(value)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The document store keeps the URL of every stored document
+	stored := make(map[uint64]string)
+	var corrupt []uint64
+	err = f.r.ScanPrefix([]byte(docPrefix), func(k, value []byte) error {
+		id := DecodeDocID(k[len(docPrefix):])
+		var doc StoredDocument
+		if err := decompressJSON(value, &doc); err != nil {
+			corrupt = append(corrupt, id)
+			return nil
+		}
+		stored[id] = doc.URL
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range corrupt {
+		err := f.issue(IssueCorruptDocument, fmt.Sprintf("stored document %d does not decode", id), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	for id, url := range stored {
+		if _, ok := f.urls[id]; ok || url == "" {
+			continue
+		}
+		id, url := id, url
+		err := f.issue(IssueDocumentMapping, fmt.Sprintf("stored document %d (%s) has no URL mapping", id, url), func() error {
+			f.urls[id] = url
+			return f.w.Put(docKey(docURLPrefix, id), []byte(url))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// A URL belongs to its newest ID
+	byURL := make(map[string]uint64)
+	for id, url := range f.urls {
+		if byURL[url] < id {
+			byURL[url] = id
+		}
+	}
+	for _, id := range sortedIDs(f.urls) {
+		url := f.urls[id]
+		if byURL[url] == id {
+			continue
+		}
+		err := f.issue(IssueDocumentMapping, fmt.Sprintf("documents %d and %d share URL %s", id, byURL[url], url), func() error {
+			delete(f.urls, id)
+			return f.w.Delete(docKey(docURLPrefix, id))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for url, id := range byURL {
+		if ids[url] == id {
+			continue
+		}
+		url, id := url, id
+		err := f.issue(IssueDocumentMapping, fmt.Sprintf("URL %s does not map to document %d", url, id), func() error {
+			return f.w.Put(key(docIDPrefix, []byte(url)), EncodeDocID(id))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for url, id := range ids {
+		if _, ok := byURL[url]; ok {
+			continue
+		}
+		url := url
+		err := f.issue(IssueDocumentMapping, fmt.Sprintf("URL %s maps to unknown document %d", url, id), func() error {
+			return f.w.Delete(key(docIDPrefix, []byte(url)))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	f.report.Documents = len(f.urls)
+	return f.checkSequence("doc", maxID(f.urls))
+}
+
+// checkSegments checks that every postings list decodes and refers to known documents,
+// and that the segment infos match the postings.
+func (f *fsck) checkSegments() error {
+	// Infos that do not decode are rebuilt like missing ones
+	byID := make(map[uint64]SegmentInfo)
+	var infoIDs, corruptInfos []uint64
+	err := f.r.ScanPrefix([]byte(segmentInfoPrefix), func(k, value []byte) error {
+		id := DecodeDocID(k[len(segmentInfoPrefix):])
+		infoIDs = append(infoIDs, id)
+		var info SegmentInfo
+		if err := json.Unmarshal(value, &info); err != nil || info.ID != id {
+			corruptInfos = append(corruptInfos, id)
+			return nil
+		}
+		byID[id] = info
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range corruptInfos {
+		id := id
+		err := f.issue(IssueSegmentStats, fmt.Sprintf("info of segment %d does not decode", id), func() error {
+			return f.w.Delete(docKey(segmentInfoPrefix, id))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Collect the postings lists of every segment
+	type list struct {
+		term  string
+		value []byte
+	}
+	segments := make(map[uint64][]list)
+	err = f.r.ScanPrefix([]byte(segmentPrefix), func(k, value []byte) error {
+		k = k[len(segmentPrefix):]
+		if len(k) < 8 {
+			return nil
+		}
+		id := DecodeDocID(k[:8])
+		segments[id] = append(segments[id], list{term: string(k[8:]), value: append([]byte{}, value...)})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	f.docTerms = make(map[uint64]map[string]uint32)
+	f.affected = make(map[uint64]bool)
+	var ids []uint64
+	for id := range segments {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+
+	for _, id := range ids {
+		actual := SegmentInfo{ID: id, Created: time.Now()}
+		if info, ok := byID[id]; ok {
+			actual.Created = info.Created
+		}
+
+		corrupt := false
+		for _, l := range segments[id] {
+			termKey := segmentKey(id, l.term)
+			postings, err := codec.DecodePostings(l.value)
+			if err == nil {
+				for _, posting := range postings {
+					if err = posting.Validate(); err != nil {
+						break
+					}
+				}
+			}
+			if err != nil {
+				// The documents recording the term may have lost their postings
+				docs, lookupErr := f.documentsWithTerm(l.term)
+				if lookupErr != nil {
+					return lookupErr
+				}
+				detail := fmt.Sprintf("postings of %q in segment %d: %v; documents to index again: %s", l.term, id, err, formatIDs(docs))
+				err := f.drop(IssueCorruptPostings, detail, func() error {
+					if err := f.reindex(docs); err != nil {
+						return err
+					}
+					return f.w.Delete(termKey)
+				})
+				if err != nil {
+					return err
+				}
+				corrupt = true
+				for _, doc := range docs {
+					f.affected[doc] = true
+				}
+				continue
+			}
+
+			// Live postings must refer to known documents
+			var live []codec.Posting
+			var unknown []string
+			for _, posting := range postings {
+				tombstone, err := f.r.Get(tombstoneKey(posting.DocID, l.term))
+				if err != nil {
+					return err
+				}
+				if _, ok := f.urls[posting.DocID]; !ok && tombstone == nil {
+					unknown = append(unknown, strconv.FormatUint(posting.DocID, 10))
+					continue
+				}
+				live = append(live, posting)
+				if tombstone == nil {
+					f.addDocTerm(posting.DocID, l.term, posting.Freq)
+				}
+			}
+
+			value := l.value
+			if len(unknown) > 0 {
+				detail := fmt.Sprintf("postings of %q in segment %d refer to unknown documents %s", l.term, id, strings.Join(unknown, ", "))
+				err := f.drop(IssueDanglingPosting, detail, func() error {
+					if len(live) == 0 {
+						return f.w.Delete(termKey)
+					}
+					var err error
+					if value, err = codec.EncodePostings(live); err != nil {
+						return err
+					}
+					return f.w.Put(termKey, value)
+				})
+				if err != nil {
+					return err
+				}
+				if f.w != nil {
+					if len(live) == 0 {
+						continue
+					}
+					postings = live
+				}
+			}
+
+			actual.Terms++
+			actual.Postings += len(postings)
+			actual.Bytes += len(l.term) + len(value)
+		}
+
+		if actual.Terms > 0 {
+			f.report.Segments++
+			f.report.Terms += actual.Terms
+			f.report.Postings += actual.Postings
+		}
+
+		// The stats of a segment with lists left undecoded are unknown
+		if corrupt && f.w == nil {
+			continue
+		}
+		if err := f.checkSegmentInfo(byID, actual); err != nil {
+			return err
+		}
+	}
+
+	// Infos of segments without postings
+	for _, id := range infoIDs {
+		if _, ok := segments[id]; ok {
+			continue
+		}
+		id := id
+		err := f.issue(IssueSegmentStats, fmt.Sprintf("segment %d has an info but no postings", id), func() error {
+			return f.w.Delete(docKey(segmentInfoPrefix, id))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var maxSegment uint64
+	if len(ids) > 0 {
+		maxSegment = ids[len(ids)-1]
+	}
+	return f.checkSequence("segment", maxSegment)
+}
+
+// checkSegmentInfo compares the info of a segment with its postings.
+func (f *fsck) checkSegmentInfo(infos map[uint64]SegmentInfo, actual SegmentInfo) error {
+	info, ok := infos[actual.ID]
+	switch {
+	case actual.Terms == 0:
+		if !ok {
+			return nil
+		}
+		return f.issue(IssueSegmentStats, fmt.Sprintf("segment %d has no postings left", actual.ID), func() error {
+			return f.w.Delete(docKey(segmentInfoPrefix, actual.ID))
+		})
+	case !ok:
+		return f.issue(IssueSegmentStats, fmt.Sprintf("segment %d has no info", actual.ID), func() error {
+			return putSegmentInfo(f.w, actual)
+		})
+	case info.Terms != actual.Terms || info.Postings != actual.Postings || info.Bytes != actual.Bytes:
+		detail := fmt.Sprintf("segment %d records %d terms, %d postings and %d bytes, but holds %d, %d and %d",
+			actual.ID, info.Terms, info.Postings, info.Bytes, actual.Terms, actual.Postings, actual.Bytes)
+		return f.issue(IssueSegmentStats, detail, func() error {
+			return putSegmentInfo(f.w, actual)
+		})
+	}
+	return nil
+}
+
+// checkDocTerms checks that the recorded terms of every document are the terms of its live postings.
+func (f *fsck) checkDocTerms() error {
+	for _, id := range sortedIDs(f.urls) {
+		value, err := f.r.Get(docKey(docTermsPrefix, id))
+		if err != nil {
+			return err
+		}
+		var recorded []string
+		if value != nil {
+			if recorded, err = decodeTerms(value); err != nil {
+				recorded = nil
+			}
+		}
+
+		var live []string
+		for term := range f.docTerms[id] {
+			live = append(live, term)
+		}
+		if value != nil && bytes.Equal(value, encodeTerms(live)) {
+			continue
+		}
+		if value == nil && len(live) == 0 {
+			continue
+		}
+
+		detail := fmt.Sprintf("document %d records %d terms, but has postings for %d", id, len(recorded), len(live))
+		if value != nil && recorded == nil {
+			detail = fmt.Sprintf("terms of document %d do not decode", id)
+		}
+		id := id
+		err = f.issue(IssueDocumentTerms, detail, func() error {
+			return f.w.Put(docKey(docTermsPrefix, id), encodeTerms(live))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDocLengths checks that the recorded length of every document is the number of
+// term occurrences of its live postings. Documents indexed from word lists have no length.
+func (f *fsck) checkDocLengths() error {
+	var orphans []uint64
+	lengths := make(map[uint64][]byte)
+	err := f.r.ScanPrefix([]byte(docLengthPrefix), func(k, value []byte) error {
+		id := DecodeDocID(k[len(docLengthPrefix):])
+		if _, ok := f.urls[id]; !ok {
+			orphans = append(orphans, id)
+			return nil
+		}
+		lengths[id] = append([]byte{}, value...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range orphans {
+		id := id
+		err := f.issue(IssueDocumentLength, fmt.Sprintf("length of unknown document %d", id), func() error {
+			return f.w.Delete(docKey(docLengthPrefix, id))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, id := range sortedIDs(f.urls) {
+		value, ok := lengths[id]
+		if !ok {
+			continue
+		}
+		var actual uint64
+		for _, freq := range f.docTerms[id] {
+			actual += uint64(freq)
+		}
+
+		recorded, n := binary.Uvarint(value)
+		var detail string
+		switch {
+		case n <= 0:
+			detail = fmt.Sprintf("length of document %d does not decode", id)
+		case recorded != actual && !f.affected[id]:
+			// Documents with lists that do not decode keep their length until they are indexed again
+			detail = fmt.Sprintf("document %d records a length of %d, but has %d term occurrences", id, recorded, actual)
+		default:
+			continue
+		}
+		id := id
+		err := f.issue(IssueDocumentLength, detail, func() error {
+			return f.w.Put(docKey(docLengthPrefix, id), binary.AppendUvarint(nil, actual))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDocValues checks that the sortable and facetable values of every document decode
+// and belong to a known document.
+func (f *fsck) checkDocValues() error {
+	var orphans, corrupt []uint64
+	err := f.r.ScanPrefix([]byte(docValuesPrefix), func(k, value []byte) error {
+		id := DecodeDocID(k[len(docValuesPrefix):])
+		if _, ok := f.urls[id]; !ok {
+			orphans = append(orphans, id)
+			return nil
+		}
+		var raw map[string][]interface{}
+		if err := json.Unmarshal(value, &raw); err != nil {
+			corrupt = append(corrupt, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Values must also have the types of the schema
+	if len(f.schema.Fields) > 0 {
+		for _, id := range sortedIDs(f.urls) {
+			if _, err := ReadDocValues(f.r, id, f.schema); err != nil && !containsID(corrupt, id) {
+				corrupt = append(corrupt, id)
+			}
+		}
+	}
+
+	for _, id := range orphans {
+		id := id
+		err := f.issue(IssueDocumentValues, fmt.Sprintf("values of unknown document %d", id), func() error {
+			return f.w.Delete(docKey(docValuesPrefix, id))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, id := range corrupt {
+		id := id
+		detail := fmt.Sprintf("values of document %d do not decode; document to index again: %d", id, id)
+		err := f.drop(IssueDocumentValues, detail, func() error {
+			if err := f.reindex([]uint64{id}); err != nil {
+				return err
+			}
+			return f.w.Delete(docKey(docValuesPrefix, id))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSequence checks that a sequence would not hand out an ID in use.
+func (f *fsck) checkSequence(name string, max uint64) error {
+	value, err := f.r.Get(key(metaPrefix+"sequence/", []byte(name)))
+	if err != nil {
+		return err
+	}
+	var current uint64
+	if len(value) == 8 {
+		current = binary.BigEndian.Uint64(value)
+	}
+	if current >= max {
+		return nil
+	}
+
+	return f.issue(IssueSequence, fmt.Sprintf("%s sequence is at %d, but IDs up to %d are in use", name, current, max), func() error {
+		return setSequence(f.w, name, max)
+	})
+}
+
+// addDocTerm records a term of a live posting of a document. Segments are checked from
+// the oldest, so the frequency kept is that of the newest version of the posting.
+func (f *fsck) addDocTerm(id uint64, term string, freq uint32) {
+	if f.docTerms[id] == nil {
+		f.docTerms[id] = make(map[string]uint32)
+	}
+	f.docTerms[id][term] = freq
+}
+
+// containsID reports whether a list of document IDs contains an ID.
+func containsID(ids []uint64, id uint64) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedIDs returns the document IDs of a map in ascending order.
+func sortedIDs(urls map[uint64]string) []uint64 {
+	ids := make([]uint64, 0, len(urls))
+	for id := range urls {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(a, b int) bool { return ids[a] < ids[b] })
+	return ids
+}
+
+// maxID returns the highest document ID of a map.
+func maxID(urls map[uint64]string) uint64 {
+	var max uint64
+	for id := range urls {
+		if id > max {
+			max = id
+		}
+	}
+	return max
+}
@@ -4,152 +4,275 @@ package indexer
 
 import (
 	"bytes"
-	"compress/gzip"
+	"encoding/binary"
 	"encoding/gob"
 	"fmt"
-	"strings"
-	"sync"
-	"time"
 
-	"github.com/boltdb/bolt"
-	"github.com/redis/go-redis/v9"
+	"github.com/Mdromi/golang-search-engine/search-engine/analyzer"
+	"github.com/Mdromi/golang-search-engine/search-engine/codec"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
 	"golang.org/x/net/context"
 )
 
 type Indexer struct {
-	db    Database
-	redis *redis.Client
+	db            storage.Store
+	cache         Cache
+	docs          *DocStore
+	mergePolicy   MergePolicy
+	cacheConfig   CacheConfig
+	cacheScope    string // collection of the cache keys, empty for the default collection
+	cacheCounters cacheCounters
+	analyzer      *analyzer.Analyzer
+	schema        Schema
 }
 
-// IndexDB is an interface that represents a database for indexing.
-type IndexDB interface {
-	Update(func(*bolt.Tx) error) error
-	View(func(*bolt.Tx) error) error
-	Close() error
-}
-
-// NewIndexer creates a new instance of the Indexer.
-func NewIndexer(db Database, redis *redis.Client) *Indexer {
+// NewIndexer creates a new instance of the Indexer caching query results in cache, which may be nil.
+func NewIndexer(db storage.Store, cache Cache) *Indexer {
 	return &Indexer{
-		db:    db,
-		redis: redis,
+		db:          db,
+		cache:       cache,
+		docs:        NewDocStore(db, DefaultDocStoreConfig()),
+		mergePolicy: DefaultMergePolicy(),
+		cacheConfig: DefaultCacheConfig(),
+		analyzer:    analyzer.New(analyzer.Config{}),
 	}
 }
 
-// Index performs the indexing of words and their associated URLs in batches.
+// Index adds words and their associated URLs to the index as a new segment.
+// The URLs are assigned document IDs and get postings without positions.
 func (i *Indexer) Index(data map[string][]string) error {
-	// Open a writable transaction
-	return i.db.Update(func(tx *bolt.Tx) error {
-		// Create or access the "IndexBucket"
-		bucket, err := tx.CreateBucketIfNotExists([]byte("IndexBucket"))
+	// Write the segment in one batch
+	return i.db.Update(func(w storage.Writer) error {
+		return indexTerms(w, data)
+	})
+}
+
+// IndexDocuments stores documents in the document store and writes the positional
+// postings of their terms as a new segment. Documents indexed before keep their ID; if their content
+// changed, the postings of terms they no longer contain are removed.
+func (i *Indexer) IndexDocuments(docs []Document) error {
+	// Write the documents and their segment in one batch
+	return i.db.Update(func(w storage.Writer) error {
+		_, err := i.indexDocuments(w, docs)
+		return err
+	})
+}
+
+// indexTerms writes postings without positions for words and their URLs within a batch.
+func indexTerms(w storage.Writer, data map[string][]string) error {
+	added := make(map[string][]codec.Posting)
+	for word, urls := range data {
+		for _, url := range urls {
+			id, err := assignDocID(w, url)
+			if err != nil {
+				return err
+			}
+			if err := addDocTerms(w, id, []string{word}); err != nil {
+				return err
+			}
+			added[word] = append(added[word], codec.Posting{DocID: id, Freq: 1})
+		}
+	}
+
+	return writeSegment(w, added)
+}
+
+// indexDocuments stores the changed documents and writes their positional postings within
+// a batch. It returns the number of documents that changed.
+func (i *Indexer) indexDocuments(w storage.Writer, docs []Document) (int, error) {
+	// Collect the new postings of every term
+	var changed int
+	added := make(map[string][]codec.Posting)
+	for _, doc := range docs {
+		// Check the document against the schema
+		values, err := i.fieldValues(doc)
 		if err != nil {
-			return err
+			return 0, err
+		}
+
+		stored := doc
+		if values != nil {
+			stored = i.schema.storedCopy(doc, values)
+		}
+		id, err := i.docs.put(w, stored)
+		if err != nil {
+			return 0, err
 		}
 
-		// Use concurrency to index words in parallel
-		var wg sync.WaitGroup
-		for word, urls := range data {
-			wg.Add(1)
-			go func(word string, urls []string) {
-				defer wg.Done()
-
-				// Use the word as the key and the URLs as the value
-				if err := bucket.Put([]byte(word), []byte(strings.Join(urls, ","))); err != nil {
-					fmt.Println("Failed to index word:", word)
-				}
-			}(word, append([]string{}, urls...)) // Pass the copy of "urls" to the goroutine
-
-			// Save data to Redis after indexing in BoltDB
-			if i.redis != nil {
-				if err := i.saveToRedis(word, urls); err != nil {
-					fmt.Println("Failed to save data to Redis:", err)
-				}
+		// Skip documents whose content is unchanged
+		ok, err := documentChanged(w, id, doc)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		changed++
+
+		terms := i.documentTerms(doc, values)
+		positions := make(map[string][]uint32)
+		var distinct []string
+		for pos, term := range terms {
+			if _, ok := positions[term]; !ok {
+				distinct = append(distinct, term)
 			}
+			positions[term] = append(positions[term], uint32(pos))
+		}
+		for term, termPositions := range positions {
+			added[term] = append(added[term], codec.Posting{DocID: id, Freq: uint32(len(termPositions)), Positions: termPositions})
 		}
 
-		wg.Wait()
-		return nil
-	})
+		// Drop the postings of terms the document no longer contains
+		if err := setDocTerms(w, id, distinct); err != nil {
+			return 0, err
+		}
+
+		// Store the document length for scoring
+		if err := w.Put(docKey(docLengthPrefix, id), binary.AppendUvarint(nil, uint64(len(terms)))); err != nil {
+			return 0, err
+		}
+
+		// Keep the values results are sorted and faceted by
+		if values != nil {
+			if err := i.schema.putDocValues(w, id, values); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return changed, writeSegment(w, added)
+}
+
+// ReadDocLength returns the number of terms in a document and reports whether it is known.
+func ReadDocLength(r storage.Reader, id uint64) (int, bool, error) {
+	value, err := r.Get(docKey(docLengthPrefix, id))
+	if err != nil || value == nil {
+		return 0, false, err
+	}
+	length, n := binary.Uvarint(value)
+	return int(length), n > 0, nil
+}
+
+// StoreDocuments stores the display copy of documents in the document store and returns their IDs.
+func (i *Indexer) StoreDocuments(docs []Document) ([]uint64, error) {
+	return i.docs.Put(docs)
+}
+
+// Documents returns the document store of the indexer.
+func (i *Indexer) Documents() *DocStore {
+	return i.docs
+}
+
+// SetDocStoreConfig selects which fields the document store keeps.
+func (i *Indexer) SetDocStoreConfig(config DocStoreConfig) {
+	i.docs = NewDocStore(i.db, config)
+}
+
+// SetAnalyzer selects the analyzer splitting documents into terms.
+func (i *Indexer) SetAnalyzer(a *analyzer.Analyzer) {
+	i.analyzer = a
+}
+
+// SetSchema selects the typed fields of the documents. Documents indexed after are
+// validated against it.
+func (i *Indexer) SetSchema(schema Schema) {
+	i.schema = schema
 }
 
 // Query searches for a given word and returns the associated URLs.
+// Results, including empty ones, are cached under keys of the current index
+// generation, so writes to the index invalidate them.
 func (i *Indexer) Query(word string) ([]string, error) {
-	// Try to get the data from Redis first
-	if i.redis != nil {
-		urls, err := i.getFromRedis(word)
-		if err == nil {
-			return urls, nil
+	if i.cache == nil {
+		urls, _, err := i.getFromStore(word)
+		return urls, err
+	}
+
+	// Try to get the data from the cache first
+	generation, err := i.Generation()
+	if err != nil {
+		return nil, err
+	}
+	urls, found, err := i.getFromCache(i.cacheKey(generation, word))
+	switch {
+	case err != nil:
+		i.cacheCounters.errors.Add(1)
+	case found:
+		i.cacheCounters.hits.Add(1)
+		if len(urls) == 0 {
+			i.cacheCounters.negativeHits.Add(1)
 		}
+		return urls, nil
 	}
+	i.cacheCounters.misses.Add(1)
 
-	// If not found in Redis or Redis is not available, try to get it from BoltDB
-	urls, err := i.getFromBoltDB(word)
+	// If not found in the cache or the cache is not available, get it from the store
+	urls, generation, err = i.getFromStore(word)
 	if err != nil {
 		return nil, err
 	}
 
-	// Save the data to Redis for future queries
-	if i.redis != nil {
-		if err := i.saveToRedis(word, urls); err != nil {
-			fmt.Println("Failed to save data to Redis:", err)
-		}
+	// Save the data to the cache for future queries, under the generation it was read in
+	if err := i.saveToCache(i.cacheKey(generation, word), urls); err != nil {
+		i.cacheCounters.errors.Add(1)
 	}
 
 	return urls, nil
 }
 
-// getFromBoltDB retrieves the compressed data from BoltDB.
-func (i *Indexer) getFromBoltDB(word string) ([]string, error) {
+// getFromStore retrieves the URLs of a word from its postings lists in the store
+// along with the index generation they were read in.
+func (i *Indexer) getFromStore(word string) ([]string, uint64, error) {
 	var urls []string
+	var generation uint64
 
-	// Open a read-only transaction
-	err := i.db.View(func(tx *bolt.Tx) error {
-		// Access the "IndexBucket"
-		bucket := tx.Bucket([]byte("IndexBucket"))
-		if bucket == nil {
-			return nil // Bucket not found, return empty result
+	// Read a consistent view of the index
+	err := i.db.View(func(r storage.Reader) error {
+		var err error
+		if generation, err = readGeneration(r); err != nil {
+			return err
 		}
 
-		// Retrieve the compressed data
-		data := bucket.Get([]byte(word))
-		if data == nil {
-			return nil // Word not found, return empty result
+		// Resolve the documents of the postings list
+		it := ReadPostings(r, word)
+		for it.Next() {
+			url, ok, err := LookupDocURL(r, it.Posting().DocID)
+			if err != nil {
+				return err
+			}
+			if ok {
+				urls = append(urls, url)
+			}
+		}
+		if err := it.Err(); err != nil {
+			return fmt.Errorf("failed to read postings of %q: %w", word, err)
 		}
-
-		// Decompress the data and return the URLs
-		urls = gzipDecompress(data)
 		return nil
 	})
 
-	return urls, err
+	return urls, generation, err
 }
 
-// getFromRedis retrieves the data from Redis.
-func (i *Indexer) getFromRedis(word string) ([]string, error) {
-	// Get the data from Redis
-	ctx := context.Background()
-	data, err := i.redis.Get(ctx, word).Result()
-	if err != nil {
-		return nil, err
+// getFromCache retrieves cached URLs and reports whether the key was found.
+func (i *Indexer) getFromCache(key string) ([]string, bool, error) {
+	// Get the data from the cache
+	data, found, err := i.cache.Get(context.Background(), key)
+	if err != nil || !found {
+		return nil, false, err
 	}
 
 	// Decode the data and return the URLs
 	var urls []string
-	err = gob.NewDecoder(bytes.NewBufferString(data)).Decode(&urls)
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&urls)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
-	return urls, nil
+	return urls, true, nil
 }
 
-// saveToRedis saves the data to Redis.
-func (i *Indexer) saveToRedis(word string, urls []string) error {
-	// If the Redis client is nil, just return without saving
-	if i.redis == nil {
-		return nil
-	}
-
+// saveToCache caches URLs, keeping empty results for the shorter negative TTL.
+func (i *Indexer) saveToCache(key string, urls []string) error {
 	// Encode the data
 	var buf bytes.Buffer
 	err := gob.NewEncoder(&buf).Encode(urls)
@@ -157,43 +280,10 @@ func (i *Indexer) saveToRedis(word string, urls []string) error {
 		return err
 	}
 
-	// Set the data in Redis with an expiration of 1 hour
-	ctx := context.Background()
-	_, err = i.redis.Set(ctx, word, buf.String(), 1*time.Hour).Result()
-	return err
-}
-
-// gzipCompress compresses a slice of strings using gzip.
-func gzipCompress(input []string) []byte {
-	var buf bytes.Buffer
-	gzw := gzip.NewWriter(&buf)
-	enc := gob.NewEncoder(gzw)
-	err := enc.Encode(input)
-	gzw.Close()
-
-	if err != nil {
-		return nil
-	}
-
-	return buf.Bytes()
-}
-
-// gzipDecompress decompresses a byte slice using gzip.
-func gzipDecompress(input []byte) []string {
-	buf := bytes.NewBuffer(input)
-	gzr, err := gzip.NewReader(buf)
-	if err != nil {
-		return nil
-	}
-	defer gzr.Close()
-
-	var output []string
-	dec := gob.NewDecoder(gzr)
-	err = dec.Decode(&output)
-
-	if err != nil {
-		return nil
+	ttl := i.cacheConfig.TTL
+	if len(urls) == 0 {
+		ttl = i.cacheConfig.NegativeTTL
 	}
 
-	return output
+	return i.cache.Set(context.Background(), key, buf.Bytes(), ttl)
 }
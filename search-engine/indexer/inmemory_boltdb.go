@@ -5,13 +5,13 @@ import (
 	"os"
 	"path/filepath"
 
-	"github.com/boltdb/bolt"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
 )
 
-// InMemoryBoltDB is an in-memory implementation of *bolt.DB for testing.
+// InMemoryBoltDB holds an index in memory for testing. It is kept for existing
+// callers; new code can use storage.NewMemoryStore directly.
 type InMemoryBoltDB struct {
-	*bolt.DB
-	data map[string][]byte
+	DB *storage.MemoryStore
 }
 
 // FindProjectRoot traverses the file system upwards to find the project root directory.
@@ -42,28 +42,8 @@ func FindProjectRoot() (string, error) {
 
 // NewInMemoryBoltDB creates a new instance of InMemoryBoltDB.
 func NewInMemoryBoltDB() (*InMemoryBoltDB, func() error) {
-	// Get the project root directory
-	projectRoot, err := FindProjectRoot()
-	if err != nil {
-		fmt.Println("Failed to find project root:", err)
-		panic(err)
-	}
-
-	// Construct the file path relative to the project root directory
-	filePath := filepath.Join(projectRoot, "data", "mydb.db")
-
-	// Create an in-memory BoltDB instance using bolt.Open.
-	// Note: You may want to use other bolt.Open options for testing purposes.
-	db, err := bolt.Open(filePath, 0666, &bolt.Options{})
-
-	if err != nil {
-		fmt.Println("Failed to create in-memory BoltDB:", err)
-		panic("Failed to create in-memory BoltDB")
-	}
-
 	inMemoryDB := &InMemoryBoltDB{
-		DB:   db,
-		data: make(map[string][]byte),
+		DB: storage.NewMemoryStore(),
 	}
 
 	// Define the cleanup function to close the database.
@@ -74,14 +54,19 @@ func NewInMemoryBoltDB() (*InMemoryBoltDB, func() error) {
 	return inMemoryDB, cleanup
 }
 
-// Update implements the Update method of the IndexDB interface.
-func (db *InMemoryBoltDB) Update(fn func(*bolt.Tx) error) error {
-	return fn(nil) // For an in-memory database, we can pass nil for the transaction.
+// Update applies the writes of fn as one batch.
+func (db *InMemoryBoltDB) Update(fn func(storage.Writer) error) error {
+	return db.DB.Update(fn)
+}
+
+// View reads the current contents of the database.
+func (db *InMemoryBoltDB) View(fn func(storage.Reader) error) error {
+	return db.DB.View(fn)
 }
 
-// View implements the View method of the IndexDB interface.
-func (db *InMemoryBoltDB) View(fn func(*bolt.Tx) error) error {
-	return fn(nil) // For an in-memory database, we can pass nil for the transaction.
+// Snapshot returns a point-in-time view of the database.
+func (db *InMemoryBoltDB) Snapshot() (storage.Snapshot, error) {
+	return db.DB.Snapshot()
 }
 
 func (db *InMemoryBoltDB) Close() error {
@@ -0,0 +1,78 @@
+package indexer
+
+import (
+	"encoding/binary"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+)
+
+// Constants for the key prefixes of the index data in the store
+const (
+	docIDPrefix          = "doc/id/"       // URL to document ID
+	docURLPrefix         = "doc/url/"      // document ID to URL
+	docPrefix            = "doc/stored/"   // document ID to compressed stored fields
+	docLengthPrefix      = "doc/length/"   // document ID to number of terms
+	docTermsPrefix       = "doc/terms/"    // document ID to indexed terms
+	docChecksumPrefix    = "doc/checksum/" // document ID to content checksum
+	docValuesPrefix      = "doc/values/"   // document ID to sortable and facetable field values
+	segmentPrefix        = "segment/"      // segment ID and term to postings list
+	segmentInfoPrefix    = "segment-info/" // segment ID to SegmentInfo
+	tombstonePrefix      = "tombstone/"    // document ID and term of a deleted posting
+	metaPrefix           = "meta/"         // index-wide values and sequences
+	legacyIndexPrefix    = "legacy/index/" // IndexBucket entries awaiting Migrate
+	collectionPrefix     = "collection/"   // collection name to CollectionConfig
+	collectionDataPrefix = "collections/"  // collection name and key of its data
+)
+
+// key joins a prefix and the parts of a key.
+func key(prefix string, parts ...[]byte) []byte {
+	k := []byte(prefix)
+	for _, part := range parts {
+		k = append(k, part...)
+	}
+	return k
+}
+
+// docKey returns the key of a document ID under a prefix.
+func docKey(prefix string, id uint64) []byte {
+	return key(prefix, EncodeDocID(id))
+}
+
+// nextSequence returns the next value of a named sequence, starting at 1.
+func nextSequence(w storage.Writer, name string) (uint64, error) {
+	k := key(metaPrefix+"sequence/", []byte(name))
+	value, err := w.Get(k)
+	if err != nil {
+		return 0, err
+	}
+
+	var next uint64 = 1
+	if len(value) == 8 {
+		next = binary.BigEndian.Uint64(value) + 1
+	}
+	return next, w.Put(k, binary.BigEndian.AppendUint64(nil, next))
+}
+
+// setSequence sets the current value of a named sequence.
+func setSequence(w storage.Writer, name string, value uint64) error {
+	return w.Put(key(metaPrefix+"sequence/", []byte(name)), binary.BigEndian.AppendUint64(nil, value))
+}
+
+// deletePrefix removes every key starting with prefix.
+func deletePrefix(w storage.Writer, prefix []byte) error {
+	var keys [][]byte
+	err := w.ScanPrefix(prefix, func(k, _ []byte) error {
+		keys = append(keys, append([]byte{}, k...))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		if err := w.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package indexer
+
+import (
+	"fmt"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/codec"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/boltdb/bolt"
+)
+
+// MigrationReport summarizes the rewrite of an index into the current format.
+type MigrationReport struct {
+	Terms     int      `json:"terms"`     // legacy words rewritten as postings lists
+	Documents int      `json:"documents"` // legacy page texts re-indexed as documents
+	Skipped   []string `json:"skipped"`   // legacy keys that could not be migrated
+}
+
+// legacyBucket is the BoltDB bucket holding the index written before the storage abstraction.
+var legacyBucket = []byte("IndexBucket")
+
+// Migrate rewrites the legacy IndexBucket into versioned postings lists and removes it.
+// Values holding URL lists, either comma-joined or gzip-compressed gob, become postings
+// without positions. Values keyed by a URL that hold page text instead are indexed as
+// documents. The IndexBucket of BoltDB files written before the storage abstraction is
+// moved into the store first.
+func (i *Indexer) Migrate() (*MigrationReport, error) {
+	report := &MigrationReport{}
+
+	// Move the bucket of older BoltDB files into the store first
+	if db, ok := i.db.(*storage.BoltStore); ok {
+		if err := db.UpdateBolt(migrateBoltBucket); err != nil {
+			return nil, fmt.Errorf("failed to migrate BoltDB bucket: %w", err)
+		}
+	}
+
+	// Apply the rewrite in one batch
+	err := i.db.Update(func(w storage.Writer) error {
+		// Sort the legacy entries by their format
+		terms := make(map[string][]string)
+		var docs []Document
+		var legacy bool
+		err := w.ScanPrefix([]byte(legacyIndexPrefix), func(k, value []byte) error {
+			legacy = true
+			word := string(k[len(legacyIndexPrefix):])
+			if urls, ok := codec.DecodeLegacyURLs(value); ok {
+				terms[word] = urls
+				return nil
+			}
+			if codec.IsAbsoluteURL(word) {
+				docs = append(docs, Document{URL: word, Body: string(value)})
+				return nil
+			}
+			report.Skipped = append(report.Skipped, word)
+			return nil
+		})
+		if err != nil || !legacy {
+			return err // Nothing to migrate
+		}
+
+		// Rewrite them as postings lists
+		if err := indexTerms(w, terms); err != nil {
+			return err
+		}
+		if _, err := i.indexDocuments(w, docs); err != nil {
+			return err
+		}
+		report.Terms += len(terms)
+		report.Documents = len(docs)
+
+		return deletePrefix(w, []byte(legacyIndexPrefix))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// migrateBoltBucket moves the IndexBucket of an older BoltDB index into the store and deletes it.
+func migrateBoltBucket(tx *bolt.Tx, w storage.Writer) error {
+	bucket := tx.Bucket(legacyBucket)
+	if bucket == nil {
+		return nil
+	}
+
+	err := bucket.ForEach(func(k, value []byte) error {
+		return w.Put(key(legacyIndexPrefix, k), value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to move %s: %w", legacyBucket, err)
+	}
+	return tx.DeleteBucket(legacyBucket)
+}
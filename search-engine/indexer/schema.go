@@ -0,0 +1,467 @@
+package indexer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/analyzer"
+	"github.com/Mdromi/golang-search-engine/search-engine/codec"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+)
+
+// Constants for the types of schema fields
+const (
+	TypeText    = "text"    // analyzed into terms for full-text search
+	TypeKeyword = "keyword" // indexed as a single exact term
+	TypeNumeric = "numeric"
+	TypeDate    = "date"
+	TypeBoolean = "boolean"
+)
+
+// Constants for the fields every document has, which a schema may declare like its own
+const (
+	FieldTitle       = "title"
+	FieldDescription = "description"
+	FieldBody        = "body"
+	FieldDate        = "date"
+)
+
+// ErrInvalidDocument is returned for documents that do not match the schema of their collection.
+var ErrInvalidDocument = errors.New("invalid document")
+
+// builtInFields are the fields every document has.
+var builtInFields = map[string]bool{FieldTitle: true, FieldDescription: true, FieldBody: true, FieldDate: true}
+
+// dateLayouts are the layouts of the dates a date field accepts as strings.
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+// FieldConfig declares a typed field of a schema.
+type FieldConfig struct {
+	Name      string           `json:"name" yaml:"name"`
+	Type      string           `json:"type" yaml:"type"`
+	Indexed   bool             `json:"indexed,omitempty" yaml:"indexed"`     // searchable if text, filterable by value or range otherwise
+	Stored    bool             `json:"stored,omitempty" yaml:"stored"`       // kept in the document store for display
+	Required  bool             `json:"required,omitempty" yaml:"required"`   // documents without the field are rejected
+	Analyzer  *analyzer.Config `json:"analyzer,omitempty" yaml:"analyzer"`   // analyzer of a text field, if not the collection's
+	Boost     float64          `json:"boost,omitempty" yaml:"boost"`         // weight of matches in a text field; 0 means 1
+	Sortable  bool             `json:"sortable,omitempty" yaml:"sortable"`   // results can be sorted by the field
+	Facetable bool             `json:"facetable,omitempty" yaml:"facetable"` // results can be counted by the values of the field
+}
+
+// Schema declares the typed fields of the documents of a collection. Without fields,
+// the title and body of documents are indexed as text and all other fields are kept
+// as they are, as they were before schemas existed. Fields a schema does not declare,
+// such as the schema.org data of crawled pages, are dropped unless it is strict.
+type Schema struct {
+	Fields []FieldConfig `json:"fields,omitempty" yaml:"fields"`
+	Strict bool          `json:"strict,omitempty" yaml:"strict"` // reject documents with undeclared fields
+}
+
+// Validate checks that the fields of a schema are consistent.
+func (s Schema) Validate() error {
+	seen := make(map[string]bool)
+	for _, field := range s.Fields {
+		if field.Name == "" || strings.ContainsAny(field.Name, ":/") {
+			return fmt.Errorf("invalid field name %q", field.Name)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("field %s is declared twice", field.Name)
+		}
+		seen[field.Name] = true
+
+		switch field.Type {
+		case TypeText:
+			if field.Sortable || field.Facetable {
+				return fmt.Errorf("text field %s cannot be sortable or facetable, declare it as keyword", field.Name)
+			}
+		case TypeKeyword, TypeNumeric, TypeDate, TypeBoolean:
+			if field.Analyzer != nil || field.Boost != 0 {
+				return fmt.Errorf("only text fields such as %s can have an analyzer or a boost", field.Name)
+			}
+		default:
+			return fmt.Errorf("field %s has unknown type %q", field.Name, field.Type)
+		}
+		if field.Boost < 0 {
+			return fmt.Errorf("field %s has a negative boost", field.Name)
+		}
+
+		// The fields every document has keep their types
+		switch field.Name {
+		case FieldTitle, FieldDescription, FieldBody:
+			if field.Type != TypeText && field.Type != TypeKeyword {
+				return fmt.Errorf("field %s must be text or keyword", field.Name)
+			}
+		case FieldDate:
+			if field.Type != TypeDate {
+				return fmt.Errorf("field %s must be a date", field.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Field returns the declaration of a field and reports whether the schema has it.
+func (s Schema) Field(name string) (FieldConfig, bool) {
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return FieldConfig{}, false
+}
+
+// TextAnalyzer returns the analyzer of a text field, which is fallback unless the field has its own.
+func (f FieldConfig) TextAnalyzer(fallback *analyzer.Analyzer) *analyzer.Analyzer {
+	if f.Analyzer == nil {
+		return fallback
+	}
+	return analyzer.New(*f.Analyzer)
+}
+
+// BoostFactor returns the weight of matches in a text field.
+func (f FieldConfig) BoostFactor() float64 {
+	if f.Boost == 0 {
+		return 1
+	}
+	return f.Boost
+}
+
+// ParseValue converts a value to the type of the field: a string for text and keyword
+// fields, a float64 for numeric fields, a UTC time.Time for dates and a bool for booleans.
+// Numbers, dates and booleans are also accepted as strings.
+func (f FieldConfig) ParseValue(value interface{}) (interface{}, error) {
+	switch f.Type {
+	case TypeText, TypeKeyword:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case TypeNumeric:
+		var n float64
+		switch v := value.(type) {
+		case float64:
+			n = v
+		case float32:
+			n = float64(v)
+		case int:
+			n = float64(v)
+		case int64:
+			n = float64(v)
+		case uint64:
+			n = float64(v)
+		case json.Number:
+			var err error
+			if n, err = v.Float64(); err != nil {
+				return nil, fmt.Errorf("field %s: %q is not a number", f.Name, v)
+			}
+		case string:
+			var err error
+			if n, err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+				return nil, fmt.Errorf("field %s: %q is not a number", f.Name, v)
+			}
+		default:
+			return nil, fmt.Errorf("field %s: %v is not a number", f.Name, value)
+		}
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("field %s: %v is not a finite number", f.Name, n)
+		}
+		return n, nil
+	case TypeDate:
+		var t time.Time
+		switch v := value.(type) {
+		case time.Time:
+			t = v
+		case string:
+			for _, layout := range dateLayouts {
+				if parsed, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+					t = parsed
+					break
+				}
+			}
+			if t.IsZero() {
+				return nil, fmt.Errorf("field %s: %q is not a date", f.Name, v)
+			}
+		}
+
+		// Dates are indexed in nanoseconds since 1970
+		if !t.IsZero() {
+			if t.Year() < 1678 || t.Year() > 2261 {
+				return nil, fmt.Errorf("field %s: %v is out of range", f.Name, t)
+			}
+			return t.UTC(), nil
+		}
+	case TypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %q is not a boolean", f.Name, v)
+			}
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("field %s: %v is not a valid %s value", f.Name, value, f.Type)
+}
+
+// FieldTerm returns the term indexing a value of a field, qualified by the field name
+// so every field has terms of its own. Text fields are indexed by the terms of their
+// analyzed words, other fields by the terms of their whole values. Numbers and dates
+// are indexed by the full-precision term of their trie, see RangeTerms.
+func FieldTerm(field string, value interface{}) string {
+	if sortable, ok := SortableValue(value); ok {
+		return trieTerm(field, 0, sortable)
+	}
+	return field + ":" + fmt.Sprint(value)
+}
+
+// SortableValue maps a numeric or date field value to a uint64 with the same order,
+// and reports whether the value is one.
+func SortableValue(value interface{}) (uint64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return codec.SortableFloat64(v), true
+	case time.Time:
+		return codec.SortableInt64(v.UnixNano()), true
+	}
+	return 0, false
+}
+
+// RangeTerms returns the trie terms of a numeric or date field matching the values
+// whose sortable form is in the inclusive range [lo, hi]. A document has one of
+// them if and only if it has a value in the range.
+func RangeTerms(field string, lo, hi uint64) []string {
+	var terms []string
+	codec.SplitRange(lo, hi, func(shift uint, prefix uint64) {
+		terms = append(terms, trieTerm(field, shift, prefix))
+	})
+	return terms
+}
+
+// valueTerms returns the terms indexing a value of a field other than text.
+func valueTerms(field string, value interface{}) []string {
+	sortable, ok := SortableValue(value)
+	if !ok {
+		return []string{FieldTerm(field, value)}
+	}
+
+	// Index every level of the trie, so ranges need few terms
+	var terms []string
+	codec.TriePrefixes(sortable, func(shift uint, prefix uint64) {
+		terms = append(terms, trieTerm(field, shift, prefix))
+	})
+	return terms
+}
+
+// trieTerm returns the term of a trie prefix of a field.
+func trieTerm(field string, shift uint, prefix uint64) string {
+	return fmt.Sprintf("%s:#%d:%x", field, shift, prefix)
+}
+
+// fieldValues returns the values of every field of the schema a document has, converted
+// to the field types, or an error wrapping ErrInvalidDocument. Undeclared fields are
+// left out, or make the document invalid if the schema is strict.
+func (s Schema) fieldValues(doc Document) (map[string][]interface{}, error) {
+	invalid := func(err error) error {
+		return fmt.Errorf("%w %s: %v", ErrInvalidDocument, doc.URL, err)
+	}
+
+	if s.Strict {
+		for name := range doc.Fields {
+			if _, ok := s.Field(name); !ok {
+				return nil, invalid(fmt.Errorf("field %s is not in the schema", name))
+			}
+		}
+	}
+
+	values := make(map[string][]interface{})
+	for _, field := range s.Fields {
+		var raw []interface{}
+		switch value := documentField(doc, field.Name).(type) {
+		case nil:
+		case []interface{}:
+			raw = value
+		case []string:
+			for _, v := range value {
+				raw = append(raw, v)
+			}
+		default:
+			raw = []interface{}{value}
+		}
+
+		for _, value := range raw {
+			parsed, err := field.ParseValue(value)
+			if err != nil {
+				return nil, invalid(err)
+			}
+			values[field.Name] = append(values[field.Name], parsed)
+		}
+		if field.Required && len(values[field.Name]) == 0 {
+			return nil, invalid(fmt.Errorf("required field %s is missing", field.Name))
+		}
+	}
+	return values, nil
+}
+
+// documentField returns the value of a field of a document, or nil if it has none.
+func documentField(doc Document, name string) interface{} {
+	if value, ok := doc.Fields[name]; ok {
+		return value
+	}
+
+	// Empty fields every document has count as missing
+	switch name {
+	case FieldTitle:
+		if doc.Title != "" {
+			return doc.Title
+		}
+	case FieldDescription:
+		if doc.Description != "" {
+			return doc.Description
+		}
+	case FieldBody:
+		if doc.Body != "" {
+			return doc.Body
+		}
+	case FieldDate:
+		if !doc.Date.IsZero() {
+			return doc.Date
+		}
+	}
+	return nil
+}
+
+// storedCopy returns the document as the document store should keep it: its typed
+// fields are the stored fields of the schema, and the fields every document has are
+// left out if the schema declares them without storing them.
+func (s Schema) storedCopy(doc Document, values map[string][]interface{}) Document {
+	stored := func(name string) bool {
+		field, ok := s.Field(name)
+		return !ok || field.Stored
+	}
+	if !stored(FieldTitle) {
+		doc.Title = ""
+	}
+	if !stored(FieldDescription) {
+		doc.Description = ""
+	}
+	if !stored(FieldBody) {
+		doc.Body = ""
+	}
+	if !stored(FieldDate) {
+		doc.Date = time.Time{}
+	}
+
+	doc.Fields = nil
+	for _, field := range s.Fields {
+		if builtInFields[field.Name] || !field.Stored || len(values[field.Name]) == 0 {
+			continue
+		}
+		if doc.Fields == nil {
+			doc.Fields = make(map[string]interface{})
+		}
+		if len(values[field.Name]) == 1 {
+			doc.Fields[field.Name] = values[field.Name][0]
+		} else {
+			doc.Fields[field.Name] = values[field.Name]
+		}
+	}
+	return doc
+}
+
+// putDocValues stores the values of the sortable and facetable fields of a document.
+func (s Schema) putDocValues(w storage.Writer, id uint64, values map[string][]interface{}) error {
+	docValues := make(map[string][]interface{})
+	for _, field := range s.Fields {
+		if (field.Sortable || field.Facetable) && len(values[field.Name]) > 0 {
+			docValues[field.Name] = values[field.Name]
+		}
+	}
+	if len(docValues) == 0 {
+		return w.Delete(docKey(docValuesPrefix, id))
+	}
+
+	value, err := json.Marshal(docValues)
+	if err != nil {
+		return err
+	}
+	return w.Put(docKey(docValuesPrefix, id), value)
+}
+
+// ReadDocValues returns the values of the sortable and facetable fields of a document,
+// converted to the field types of the schema.
+func ReadDocValues(r storage.Reader, id uint64, schema Schema) (map[string][]interface{}, error) {
+	value, err := r.Get(docKey(docValuesPrefix, id))
+	if err != nil || value == nil {
+		return nil, err
+	}
+
+	var raw map[string][]interface{}
+	if err := json.Unmarshal(value, &raw); err != nil {
+		return nil, fmt.Errorf("failed to read field values of document %d: %w", id, err)
+	}
+	values := make(map[string][]interface{}, len(raw))
+	for name, list := range raw {
+		field, ok := schema.Field(name)
+		if !ok {
+			continue
+		}
+		for _, v := range list {
+			parsed, err := field.ParseValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read field values of document %d: %w", id, err)
+			}
+			values[name] = append(values[name], parsed)
+		}
+	}
+	return values, nil
+}
+
+// ValidateDocument checks a document against the schema of the indexer, returning
+// an error wrapping ErrInvalidDocument if it does not match.
+func (i *Indexer) ValidateDocument(doc Document) error {
+	_, err := i.fieldValues(doc)
+	return err
+}
+
+// fieldValues returns the typed values of the fields of a document, or nil without a schema.
+func (i *Indexer) fieldValues(doc Document) (map[string][]interface{}, error) {
+	if len(i.schema.Fields) == 0 {
+		return nil, nil
+	}
+	return i.schema.fieldValues(doc)
+}
+
+// documentTerms returns the terms of a document in order: the analyzed title and body
+// without a schema, or the terms of the indexed fields of the schema.
+func (i *Indexer) documentTerms(doc Document, values map[string][]interface{}) []string {
+	if values == nil {
+		return i.analyzer.Analyze(doc.Title + " " + doc.Body)
+	}
+
+	var terms []string
+	for _, field := range i.schema.Fields {
+		if !field.Indexed {
+			continue
+		}
+		var textAnalyzer *analyzer.Analyzer
+		if field.Type == TypeText {
+			textAnalyzer = field.TextAnalyzer(i.analyzer)
+		}
+		for _, value := range values[field.Name] {
+			if textAnalyzer == nil {
+				terms = append(terms, valueTerms(field.Name, value)...)
+				continue
+			}
+			for _, word := range textAnalyzer.Analyze(value.(string)) {
+				terms = append(terms, FieldTerm(field.Name, word))
+			}
+		}
+	}
+	return terms
+}
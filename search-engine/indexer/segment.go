@@ -0,0 +1,411 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/codec"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+)
+
+// SegmentInfo describes an immutable index segment.
+type SegmentInfo struct {
+	ID       uint64    `json:"id"`
+	Terms    int       `json:"terms"`
+	Postings int       `json:"postings"`
+	Bytes    int       `json:"bytes"` // encoded size of the terms and postings lists
+	Created  time.Time `json:"created"`
+}
+
+// SegmentStats summarizes the segments of an index.
+type SegmentStats struct {
+	Segments []SegmentInfo `json:"segments"` // oldest first
+	Postings int           `json:"postings"`
+	Bytes    int           `json:"bytes"`
+}
+
+// MergePolicy decides which segments are merged in the background.
+// Segments fall into size tiers growing by a factor of SegmentsPerTier; once SegmentsPerTier
+// adjacent segments share a tier, they are merged into a single segment of the next tier.
+type MergePolicy struct {
+	SegmentsPerTier int `yaml:"segmentsPerTier"`
+	MinSegmentBytes int `yaml:"minSegmentBytes"` // segments smaller than this share the lowest tier
+}
+
+// DefaultMergePolicy returns a policy merging 10 segments per tier with a 64 KiB lowest tier.
+func DefaultMergePolicy() MergePolicy {
+	return MergePolicy{
+		SegmentsPerTier: 10,
+		MinSegmentBytes: 64 << 10,
+	}
+}
+
+// tier returns the size tier of a segment.
+func (p MergePolicy) tier(bytes int) int {
+	tier := 0
+	for limit := p.MinSegmentBytes; bytes >= limit && limit > 0; limit *= p.SegmentsPerTier {
+		tier++
+	}
+	return tier
+}
+
+// findMerge returns the oldest run of adjacent segments that should be merged, if any.
+// Only adjacent segments are merged so newer postings keep taking precedence over older ones.
+func (p MergePolicy) findMerge(segments []SegmentInfo) []SegmentInfo {
+	if p.SegmentsPerTier < 2 {
+		return nil
+	}
+
+	start := 0
+	for end := 1; end <= len(segments); end++ {
+		if end < len(segments) && p.tier(segments[end].Bytes) == p.tier(segments[start].Bytes) {
+			if end-start+1 == p.SegmentsPerTier {
+				return segments[start : end+1]
+			}
+			continue
+		}
+		start = end
+	}
+	return nil
+}
+
+// PostingsIterator iterates over the postings of a term across all segments, newest
+// segment first for every document, skipping the postings marked by tombstones.
+type PostingsIterator struct {
+	term     string
+	r        storage.Reader
+	segments []*codec.PostingsIterator // newest first
+	heads    []*codec.Posting
+	posting  codec.Posting
+	err      error
+}
+
+// ReadPostings returns an iterator over the live postings of a term. The iterator
+// must be used within the View or Update the reader belongs to.
+func ReadPostings(r storage.Reader, term string) *PostingsIterator {
+	it := &PostingsIterator{term: term, r: r}
+	ids, err := segmentIDs(r)
+	if err != nil {
+		it.err = err
+		return it
+	}
+	for j := len(ids) - 1; j >= 0; j-- {
+		value, err := r.Get(segmentKey(ids[j], term))
+		if err != nil {
+			it.err = err
+			return it
+		}
+		if value != nil {
+			it.segments = append(it.segments, codec.NewPostingsIterator(value))
+		}
+	}
+
+	it.heads = make([]*codec.Posting, len(it.segments))
+	for j := range it.segments {
+		it.advance(j)
+	}
+	return it
+}
+
+// Next advances to the next live posting and reports whether there is one.
+func (it *PostingsIterator) Next() bool {
+	for it.err == nil {
+		// Find the lowest document ID, preferring the newest segment
+		next := -1
+		for j, head := range it.heads {
+			if head != nil && (next < 0 || head.DocID < it.heads[next].DocID) {
+				next = j
+			}
+		}
+		if next < 0 {
+			return false
+		}
+		it.posting = *it.heads[next]
+
+		// Skip the older versions of the posting
+		for j, head := range it.heads {
+			if head != nil && head.DocID == it.posting.DocID {
+				it.advance(j)
+			}
+		}
+
+		tombstone, err := it.r.Get(tombstoneKey(it.posting.DocID, it.term))
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if tombstone == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Posting returns the current posting.
+func (it *PostingsIterator) Posting() codec.Posting {
+	return it.posting
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *PostingsIterator) Err() error {
+	return it.err
+}
+
+// advance moves the head of a segment to its next posting.
+func (it *PostingsIterator) advance(j int) {
+	if !it.segments[j].Next() {
+		it.heads[j] = nil
+		if err := it.segments[j].Err(); err != nil && it.err == nil {
+			it.err = err
+		}
+		return
+	}
+	posting := it.segments[j].Posting()
+	it.heads[j] = &posting
+}
+
+// SegmentStats returns the sizes of the index segments.
+func (i *Indexer) SegmentStats() (SegmentStats, error) {
+	var stats SegmentStats
+	err := i.db.View(func(r storage.Reader) error {
+		segments, err := readSegmentInfos(r)
+		if err != nil {
+			return err
+		}
+
+		stats.Segments = segments
+		for _, segment := range segments {
+			stats.Postings += segment.Postings
+			stats.Bytes += segment.Bytes
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// SetMergePolicy sets the policy used by Merge.
+func (i *Indexer) SetMergePolicy(policy MergePolicy) {
+	i.mergePolicy = policy
+}
+
+// Merge merges segments as long as the merge policy finds runs of segments to merge,
+// dropping the postings of deleted documents. It returns the number of merges.
+func (i *Indexer) Merge() (int, error) {
+	merges := 0
+	for {
+		var merged bool
+
+		// Every merge is a batch of its own, so writers are not blocked for long
+		err := i.db.Update(func(w storage.Writer) error {
+			segments, err := readSegmentInfos(w)
+			if err != nil {
+				return err
+			}
+
+			run := i.mergePolicy.findMerge(segments)
+			if run == nil {
+				return nil
+			}
+			merged = true
+			return mergeSegments(w, run)
+		})
+		if err != nil || !merged {
+			return merges, err
+		}
+		merges++
+	}
+}
+
+// ForceMerge merges all segments into one.
+func (i *Indexer) ForceMerge() error {
+	return i.db.Update(func(w storage.Writer) error {
+		segments, err := readSegmentInfos(w)
+		if err != nil || len(segments) < 2 {
+			return err
+		}
+		return mergeSegments(w, segments)
+	})
+}
+
+// RunMerges runs Merge on the given interval until the context is cancelled.
+func (i *Indexer) RunMerges(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if _, err := i.Merge(); err != nil {
+			return err
+		}
+	}
+}
+
+// writeSegment writes postings as a new segment within a batch.
+func writeSegment(w storage.Writer, postings map[string][]codec.Posting) error {
+	if len(postings) == 0 {
+		return nil
+	}
+
+	// New postings change query results
+	if err := bumpGeneration(w); err != nil {
+		return err
+	}
+
+	id, err := nextSequence(w, "segment")
+	if err != nil {
+		return err
+	}
+
+	return putSegment(w, SegmentInfo{ID: id, Created: time.Now()}, postings)
+}
+
+// putSegment writes the postings of a segment, sorting them by document ID
+// and keeping the last posting of every document.
+func putSegment(w storage.Writer, info SegmentInfo, postings map[string][]codec.Posting) error {
+	// Ordered stores fill pages best with keys inserted in order
+	terms := make([]string, 0, len(postings))
+	for term := range postings {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	for _, term := range terms {
+		list := append([]codec.Posting{}, postings[term]...)
+		sort.SliceStable(list, func(a, b int) bool {
+			return list[a].DocID < list[b].DocID
+		})
+		unique := list[:0]
+		for _, posting := range list {
+			if len(unique) > 0 && unique[len(unique)-1].DocID == posting.DocID {
+				unique[len(unique)-1] = posting
+				continue
+			}
+			unique = append(unique, posting)
+		}
+		if len(unique) == 0 {
+			continue
+		}
+
+		data, err := codec.EncodePostings(unique)
+		if err != nil {
+			return fmt.Errorf("failed to encode postings of %q: %w", term, err)
+		}
+		if err := w.Put(segmentKey(info.ID, term), data); err != nil {
+			return err
+		}
+		info.Terms++
+		info.Postings += len(unique)
+		info.Bytes += len(term) + len(data)
+	}
+
+	return putSegmentInfo(w, info)
+}
+
+// mergeSegments replaces adjacent segments by a single segment holding the newest posting
+// of every document, without the postings marked by tombstones.
+func mergeSegments(w storage.Writer, run []SegmentInfo) error {
+	// Read the segments oldest first, so newer postings replace older ones
+	merged := make(map[string]map[uint64]codec.Posting)
+	for _, info := range run {
+		prefix := segmentKey(info.ID, "")
+		err := w.ScanPrefix(prefix, func(k, value []byte) error {
+			term := k[len(prefix):]
+			postings, err := codec.DecodePostings(value)
+			if err != nil {
+				return fmt.Errorf("failed to read postings of %q in segment %d: %w", term, info.ID, err)
+			}
+			if merged[string(term)] == nil {
+				merged[string(term)] = make(map[uint64]codec.Posting)
+			}
+			for _, posting := range postings {
+				merged[string(term)][posting.DocID] = posting
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Drop the postings of deleted documents
+	postings := make(map[string][]codec.Posting, len(merged))
+	for term, docs := range merged {
+		for id, posting := range docs {
+			tombstone, err := w.Get(tombstoneKey(id, term))
+			if err != nil {
+				return err
+			}
+			if tombstone != nil {
+				continue
+			}
+			postings[term] = append(postings[term], posting)
+		}
+	}
+
+	for _, info := range run {
+		if err := deleteSegment(w, info.ID); err != nil {
+			return err
+		}
+	}
+
+	// The merged segment takes the place of the newest one
+	if len(postings) == 0 {
+		return nil
+	}
+	newest := run[len(run)-1]
+	return putSegment(w, SegmentInfo{ID: newest.ID, Created: newest.Created}, postings)
+}
+
+// deleteSegment removes a segment and its info within a batch.
+func deleteSegment(w storage.Writer, id uint64) error {
+	if err := deletePrefix(w, segmentKey(id, "")); err != nil {
+		return err
+	}
+	return w.Delete(docKey(segmentInfoPrefix, id))
+}
+
+// segmentKey returns the key of the postings list of a term in a segment.
+func segmentKey(id uint64, term string) []byte {
+	return key(segmentPrefix, EncodeDocID(id), []byte(term))
+}
+
+// segmentIDs returns the IDs of the segments, oldest first.
+func segmentIDs(r storage.Reader) ([]uint64, error) {
+	var ids []uint64
+	err := r.ScanPrefix([]byte(segmentInfoPrefix), func(k, _ []byte) error {
+		ids = append(ids, DecodeDocID(k[len(segmentInfoPrefix):]))
+		return nil
+	})
+	return ids, err
+}
+
+// readSegmentInfos returns the info of every segment, oldest first.
+func readSegmentInfos(r storage.Reader) ([]SegmentInfo, error) {
+	var segments []SegmentInfo
+	err := r.ScanPrefix([]byte(segmentInfoPrefix), func(k, value []byte) error {
+		var info SegmentInfo
+		if err := json.Unmarshal(value, &info); err != nil {
+			return fmt.Errorf("failed to read info of segment %d: %w", DecodeDocID(k[len(segmentInfoPrefix):]), err)
+		}
+		segments = append(segments, info)
+		return nil
+	})
+	return segments, err
+}
+
+// putSegmentInfo stores the info of a segment within a batch.
+func putSegmentInfo(w storage.Writer, info SegmentInfo) error {
+	value, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return w.Put(docKey(segmentInfoPrefix, info.ID), value)
+}
@@ -0,0 +1,108 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+)
+
+// snapshotNameLayout names the snapshots written by RunSnapshots after their time.
+const snapshotNameLayout = "20060102T150405Z"
+
+// SnapshotConfig configures the periodic snapshots of a running index.
+type SnapshotConfig struct {
+	Dir       string        `yaml:"dir"`       // directory holding one subdirectory per snapshot
+	Interval  time.Duration `yaml:"interval"`  // time between snapshots; 0 disables them
+	FullEvery int           `yaml:"fullEvery"` // every FullEvery-th snapshot is full, the others are incremental
+}
+
+// DefaultSnapshotConfig returns a config without periodic snapshots, which when enabled
+// writes a full snapshot followed by 23 incremental ones.
+func DefaultSnapshotConfig() SnapshotConfig {
+	return SnapshotConfig{
+		Dir:       "data/snapshots",
+		FullEvery: 24,
+	}
+}
+
+// Snapshot writes a consistent point-in-time copy of the index into dir without blocking writes.
+// If base names an earlier snapshot, only the chunks that changed since are written.
+func (i *Indexer) Snapshot(dir, base string) (*storage.Manifest, error) {
+	snapshot, err := i.db.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
+	return storage.WriteSnapshot(snapshot, dir, base)
+}
+
+// Restore writes the snapshot in dir into the index, which must be empty.
+func (i *Indexer) Restore(dir string) (*storage.Manifest, error) {
+	return storage.RestoreSnapshot(i.db, dir)
+}
+
+// RunSnapshots writes a snapshot into a new subdirectory of the config's directory on
+// the given interval until the context is cancelled.
+func (i *Indexer) RunSnapshots(ctx context.Context, config SnapshotConfig) error {
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	for taken := 0; ; taken++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// Base the snapshot on the latest one, unless a full one is due
+		var base string
+		if config.FullEvery > 1 && taken%config.FullEvery != 0 {
+			latest, err := LatestSnapshot(config.Dir)
+			if err != nil {
+				return err
+			}
+			base = latest
+		}
+
+		dir := filepath.Join(config.Dir, time.Now().UTC().Format(snapshotNameLayout))
+		if _, err := i.Snapshot(dir, base); err != nil {
+			return fmt.Errorf("failed to write snapshot %s: %w", dir, err)
+		}
+	}
+}
+
+// LatestSnapshot returns the newest complete snapshot written by RunSnapshots into dir,
+// or an empty string if there is none.
+func LatestSnapshot(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if _, err := time.Parse(snapshotNameLayout, entry.Name()); err == nil && entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	// Skip snapshots interrupted before their manifest was written
+	for j := len(names) - 1; j >= 0; j-- {
+		path := filepath.Join(dir, names[j])
+		if _, err := os.Stat(filepath.Join(path, storage.ManifestFile)); err == nil {
+			return path, nil
+		}
+	}
+	return "", nil
+}
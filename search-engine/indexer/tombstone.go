@@ -0,0 +1,342 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/codec"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+)
+
+// UpdateDocument re-indexes a document if its content changed since it was last indexed,
+// removing the postings of terms it no longer contains. It reports whether the document changed.
+func (i *Indexer) UpdateDocument(doc Document) (bool, error) {
+	var changed int
+
+	err := i.db.Update(func(w storage.Writer) error {
+		var err error
+		changed, err = i.indexDocuments(w, []Document{doc})
+		return err
+	})
+
+	return changed > 0, err
+}
+
+// DeleteDocument removes the document of a URL from the index and reports whether it existed.
+// Its postings are hidden by tombstones until the next Cleanup.
+func (i *Indexer) DeleteDocument(url string) (bool, error) {
+	var deleted bool
+
+	err := i.db.Update(func(w storage.Writer) error {
+		id, ok, err := LookupDocID(w, url)
+		if err != nil || !ok {
+			return err
+		}
+		deleted = true
+		return deleteDocument(w, id)
+	})
+
+	return deleted, err
+}
+
+// DeleteDocumentByID removes a document from the index by ID and reports whether it existed.
+func (i *Indexer) DeleteDocumentByID(id uint64) (bool, error) {
+	var deleted bool
+
+	err := i.db.Update(func(w storage.Writer) error {
+		_, ok, err := LookupDocURL(w, id)
+		if err != nil || !ok {
+			return err
+		}
+		deleted = true
+		return deleteDocument(w, id)
+	})
+
+	return deleted, err
+}
+
+// Cleanup removes the postings marked by tombstones from every segment, drops the
+// tombstones and returns the number of postings removed.
+func (i *Indexer) Cleanup() (int, error) {
+	var removed int
+
+	err := i.db.Update(func(w storage.Writer) error {
+		// Group the deleted documents by term
+		deleted := make(map[string]map[uint64]bool)
+		err := w.ScanPrefix([]byte(tombstonePrefix), func(k, _ []byte) error {
+			k = k[len(tombstonePrefix):]
+			if len(k) < 8 {
+				return fmt.Errorf("invalid tombstone %x", k)
+			}
+			term := string(k[8:])
+			if deleted[term] == nil {
+				deleted[term] = make(map[uint64]bool)
+			}
+			deleted[term][DecodeDocID(k[:8])] = true
+			return nil
+		})
+		if err != nil || len(deleted) == 0 {
+			return err
+		}
+
+		// Rewrite the segments holding them
+		segments, err := readSegmentInfos(w)
+		if err != nil {
+			return err
+		}
+		for _, info := range segments {
+			n, err := purgeSegment(w, info, deleted)
+			if err != nil {
+				return err
+			}
+			removed += n
+		}
+
+		return deletePrefix(w, []byte(tombstonePrefix))
+	})
+
+	return removed, err
+}
+
+// RunCleanup runs Cleanup on the given interval until the context is cancelled.
+func (i *Indexer) RunCleanup(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if _, err := i.Cleanup(); err != nil {
+			return err
+		}
+	}
+}
+
+// purgeSegment removes the postings of deleted documents from a segment and returns their number.
+func purgeSegment(w storage.Writer, info SegmentInfo, deleted map[string]map[uint64]bool) (int, error) {
+	removed := 0
+	for term, ids := range deleted {
+		termKey := segmentKey(info.ID, term)
+		value, err := w.Get(termKey)
+		if err != nil {
+			return 0, err
+		}
+		if value == nil {
+			continue
+		}
+		postings, err := codec.DecodePostings(value)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read postings of %q in segment %d: %w", term, info.ID, err)
+		}
+
+		live := postings[:0]
+		for _, posting := range postings {
+			if !ids[posting.DocID] {
+				live = append(live, posting)
+			}
+		}
+		if len(live) == len(postings) {
+			continue
+		}
+		removed += len(postings) - len(live)
+		info.Postings -= len(postings) - len(live)
+		info.Bytes -= len(value)
+
+		if len(live) == 0 {
+			info.Terms--
+			info.Bytes -= len(term)
+			err = w.Delete(termKey)
+		} else {
+			var data []byte
+			if data, err = codec.EncodePostings(live); err == nil {
+				info.Bytes += len(data)
+				err = w.Put(termKey, data)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if removed == 0 {
+		return 0, nil
+	}
+	if info.Terms == 0 {
+		return removed, deleteSegment(w, info.ID)
+	}
+	return removed, putSegmentInfo(w, info)
+}
+
+// deleteDocument tombstones the postings of a document and removes its stored data within a batch.
+func deleteDocument(w storage.Writer, id uint64) error {
+	url, ok, err := LookupDocURL(w, id)
+	if err != nil {
+		return err
+	}
+
+	terms, err := readDocTerms(w, id)
+	if err != nil {
+		return err
+	}
+	if err := addTombstones(w, id, terms); err != nil {
+		return err
+	}
+
+	// Forget the document, a URL indexed again gets a new ID
+	for _, prefix := range []string{docPrefix, docURLPrefix, docLengthPrefix, docTermsPrefix, docChecksumPrefix, docValuesPrefix} {
+		if err := w.Delete(docKey(prefix, id)); err != nil {
+			return err
+		}
+	}
+	if ok {
+		return w.Delete(key(docIDPrefix, []byte(url)))
+	}
+	return nil
+}
+
+// setDocTerms records the terms of a document, tombstoning the postings of the terms it lost.
+func setDocTerms(w storage.Writer, id uint64, terms []string) error {
+	old, err := readDocTerms(w, id)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		current[term] = true
+	}
+	var stale []string
+	for _, term := range old {
+		if !current[term] {
+			stale = append(stale, term)
+		}
+	}
+	if err := addTombstones(w, id, stale); err != nil {
+		return err
+	}
+
+	// Postings written again are live, even if they were tombstoned before
+	for _, term := range terms {
+		if err := w.Delete(tombstoneKey(id, term)); err != nil {
+			return err
+		}
+	}
+
+	return w.Put(docKey(docTermsPrefix, id), encodeTerms(terms))
+}
+
+// addDocTerms adds terms to the recorded terms of a document.
+func addDocTerms(w storage.Writer, id uint64, terms []string) error {
+	old, err := readDocTerms(w, id)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(old)+len(terms))
+	var merged []string
+	for _, term := range append(old, terms...) {
+		if !seen[term] {
+			seen[term] = true
+			merged = append(merged, term)
+		}
+	}
+	return setDocTerms(w, id, merged)
+}
+
+// readDocTerms returns the terms recorded for a document.
+func readDocTerms(r storage.Reader, id uint64) ([]string, error) {
+	value, err := r.Get(docKey(docTermsPrefix, id))
+	if err != nil || value == nil {
+		return nil, err
+	}
+	terms, err := decodeTerms(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read terms of document %d: %w", id, err)
+	}
+	return terms, nil
+}
+
+// addTombstones marks the postings of a document for the given terms as deleted.
+func addTombstones(w storage.Writer, id uint64, terms []string) error {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	// Deleted postings change query results
+	if err := bumpGeneration(w); err != nil {
+		return err
+	}
+
+	for _, term := range terms {
+		if err := w.Put(tombstoneKey(id, term), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tombstoneKey returns the key of the tombstone of a document's posting for a term.
+func tombstoneKey(id uint64, term string) []byte {
+	return key(tombstonePrefix, EncodeDocID(id), []byte(term))
+}
+
+// documentChanged records the checksum of a document and reports whether it differs from the stored one.
+func documentChanged(w storage.Writer, id uint64, doc Document) (bool, error) {
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return false, err
+	}
+	checksum := sha256.Sum256(content)
+
+	old, err := w.Get(docKey(docChecksumPrefix, id))
+	if err != nil {
+		return false, err
+	}
+	if string(old) == string(checksum[:]) {
+		return false, nil
+	}
+	return true, w.Put(docKey(docChecksumPrefix, id), checksum[:])
+}
+
+// encodeTerms encodes a sorted list of terms as length-prefixed strings.
+func encodeTerms(terms []string) []byte {
+	sorted := append([]string{}, terms...)
+	sort.Strings(sorted)
+
+	buf := binary.AppendUvarint(nil, uint64(len(sorted)))
+	for _, term := range sorted {
+		buf = binary.AppendUvarint(buf, uint64(len(term)))
+		buf = append(buf, term...)
+	}
+	return buf
+}
+
+// decodeTerms decodes a list of terms written by encodeTerms.
+func decodeTerms(data []byte) ([]string, error) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return nil, errors.New("corrupt term list")
+	}
+	data = data[n:]
+
+	terms := make([]string, 0, count)
+	for j := uint64(0); j < count; j++ {
+		length, n := binary.Uvarint(data)
+		if n <= 0 || length > uint64(len(data)-n) {
+			return nil, errors.New("corrupt term list")
+		}
+		terms = append(terms, string(data[n:n+int(length)]))
+		data = data[n+int(length):]
+	}
+	return terms, nil
+}
@@ -0,0 +1,137 @@
+package indexer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// Constants for the operations recorded in the write-ahead log
+const (
+	walAdd    = "add"
+	walDelete = "delete"
+)
+
+// walEntry is a single operation recorded in the write-ahead log.
+type walEntry struct {
+	Op  string    `json:"op"`
+	Doc *Document `json:"doc,omitempty"`
+	URL string    `json:"url,omitempty"`
+}
+
+// writeAheadLog is an append-only log of the operations not yet committed to the index.
+// Every record is a varint length, a CRC-32 checksum and the JSON-encoded entry.
+type writeAheadLog struct {
+	file *os.File
+}
+
+// openWriteAheadLog opens or creates the write-ahead log at path.
+func openWriteAheadLog(path string) (*writeAheadLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &writeAheadLog{file: file}, nil
+}
+
+// append writes entries to the end of the log and syncs them to disk.
+func (w *writeAheadLog) append(entries ...walEntry) error {
+	var buf []byte
+	for _, entry := range entries {
+		payload, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(payload)))
+		buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(payload))
+		buf = append(buf, payload...)
+	}
+
+	if _, err := w.file.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := w.file.Write(buf); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// replay calls fn for every entry in the log. A torn or corrupt record at the end,
+// left by a crash during a write that was never acknowledged, is truncated.
+func (w *writeAheadLog) replay(fn func(walEntry) error) error {
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(w.file)
+	var offset int64
+	for {
+		entry, n, err := readWALEntry(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// Drop the incomplete tail
+			if err := w.file.Truncate(offset); err != nil {
+				return err
+			}
+			return w.file.Sync()
+		}
+		offset += n
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// reset empties the log once its entries are committed.
+func (w *writeAheadLog) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// Close closes the log file.
+func (w *writeAheadLog) Close() error {
+	return w.file.Close()
+}
+
+// maxWALRecord is the size above which a record length is taken as corrupt.
+const maxWALRecord = 64 << 20
+
+// errTornRecord is returned for a record that was not written completely.
+var errTornRecord = errors.New("torn write-ahead log record")
+
+// readWALEntry reads a record and returns its entry and size.
+func readWALEntry(r *bufio.Reader) (walEntry, int64, error) {
+	var entry walEntry
+
+	length, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		return entry, 0, io.EOF
+	}
+	if err != nil || length > maxWALRecord {
+		return entry, 0, errTornRecord
+	}
+
+	record := make([]byte, 4+length)
+	if _, err := io.ReadFull(r, record); err != nil {
+		return entry, 0, errTornRecord
+	}
+	payload := record[4:]
+	if binary.BigEndian.Uint32(record) != crc32.ChecksumIEEE(payload) {
+		return entry, 0, errTornRecord
+	}
+	if err := json.Unmarshal(payload, &entry); err != nil {
+		return entry, 0, fmt.Errorf("%w: %v", errTornRecord, err)
+	}
+
+	return entry, int64(len(binary.AppendUvarint(nil, length))) + int64(len(record)), nil
+}
@@ -0,0 +1,177 @@
+package indexer
+
+import (
+	"fmt"
+	"sync"
+)
+
+// WriterConfig configures the batched ingest of a Writer.
+type WriterConfig struct {
+	BatchSize int    `yaml:"batchSize"` // documents committed as one segment; 0 commits only on Commit
+	WALPath   string `yaml:"walPath"`   // write-ahead log of uncommitted documents; empty disables it
+}
+
+// DefaultWriterConfig returns a config committing every 500 documents without a write-ahead log.
+func DefaultWriterConfig() WriterConfig {
+	return WriterConfig{
+		BatchSize: 500,
+	}
+}
+
+// Writer ingests documents in batches. Added documents are recorded in the write-ahead
+// log before Add returns, so they survive a crash, and become searchable once their
+// batch is committed.
+type Writer struct {
+	mutex     sync.Mutex
+	idx       *Indexer
+	batchSize int
+	wal       *writeAheadLog
+	pending   []Document
+}
+
+// NewWriter creates a new instance of Writer. If the config names a write-ahead log,
+// the documents left in it by a previous run are committed first.
+func NewWriter(idx *Indexer, config WriterConfig) (*Writer, error) {
+	w := &Writer{
+		idx:       idx,
+		batchSize: config.BatchSize,
+	}
+	if config.WALPath == "" {
+		return w, nil
+	}
+
+	wal, err := openWriteAheadLog(config.WALPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open write-ahead log: %w", err)
+	}
+	w.wal = wal
+
+	// Recover the documents acknowledged before a crash
+	err = wal.replay(func(entry walEntry) error {
+		switch entry.Op {
+		case walAdd:
+			if entry.Doc != nil {
+				w.pending = append(w.pending, *entry.Doc)
+			}
+			return nil
+		case walDelete:
+			return w.delete(entry.URL)
+		default:
+			return fmt.Errorf("unknown write-ahead log operation %q", entry.Op)
+		}
+	})
+	if err == nil {
+		err = w.commit()
+	}
+	if err != nil {
+		wal.Close()
+		return nil, fmt.Errorf("failed to recover write-ahead log: %w", err)
+	}
+
+	return w, nil
+}
+
+// Add records documents in the write-ahead log and buffers them, committing every full batch.
+// Documents not matching the schema of the index are rejected before any is recorded.
+func (w *Writer) Add(docs ...Document) error {
+	for _, doc := range docs {
+		if err := w.idx.ValidateDocument(doc); err != nil {
+			return err
+		}
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.wal != nil {
+		entries := make([]walEntry, len(docs))
+		for i := range docs {
+			entries[i] = walEntry{Op: walAdd, Doc: &docs[i]}
+		}
+		if err := w.wal.append(entries...); err != nil {
+			return fmt.Errorf("failed to write to write-ahead log: %w", err)
+		}
+	}
+
+	w.pending = append(w.pending, docs...)
+	if w.batchSize > 0 && len(w.pending) >= w.batchSize {
+		return w.commit()
+	}
+	return nil
+}
+
+// Delete drops the buffered copies of a URL and removes its document from the index.
+func (w *Writer) Delete(url string) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	// Keep a replay from resurrecting the logged copies
+	if w.wal != nil {
+		if err := w.wal.append(walEntry{Op: walDelete, URL: url}); err != nil {
+			return fmt.Errorf("failed to write to write-ahead log: %w", err)
+		}
+	}
+
+	return w.delete(url)
+}
+
+// Commit indexes the buffered documents as one segment and makes them searchable.
+func (w *Writer) Commit() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.commit()
+}
+
+// Pending returns the number of documents waiting to be committed.
+func (w *Writer) Pending() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return len(w.pending)
+}
+
+// Close commits the buffered documents and closes the write-ahead log.
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	err := w.commit()
+	if w.wal != nil {
+		if closeErr := w.wal.Close(); err == nil {
+			err = closeErr
+		}
+		w.wal = nil
+	}
+	return err
+}
+
+// commit indexes the buffered documents and empties the write-ahead log.
+// Committing the same documents twice after a crash is harmless, as unchanged documents are skipped.
+func (w *Writer) commit() error {
+	if len(w.pending) > 0 {
+		if err := w.idx.IndexDocuments(w.pending); err != nil {
+			return fmt.Errorf("failed to index documents: %w", err)
+		}
+		w.pending = nil
+	}
+
+	if w.wal != nil {
+		return w.wal.reset()
+	}
+	return nil
+}
+
+// delete drops the buffered copies of a URL and removes its document from the index.
+func (w *Writer) delete(url string) error {
+	pending := w.pending[:0]
+	for _, doc := range w.pending {
+		if doc.URL != url {
+			pending = append(pending, doc)
+		}
+	}
+	w.pending = pending
+
+	_, err := w.idx.DeleteDocument(url)
+	return err
+}
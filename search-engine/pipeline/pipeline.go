@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+)
+
+// Pipeline turns parsed documents into index entries.
+// It is shared by the live crawler and the offline ingestion sources.
+type Pipeline struct {
+	writer *indexer.Writer
+}
+
+// NewPipeline creates a new instance of Pipeline writing to the given indexer.
+// Documents are buffered in memory until Flush.
+func NewPipeline(idx *indexer.Indexer) *Pipeline {
+	writer, _ := indexer.NewWriter(idx, indexer.WriterConfig{}) // cannot fail without a write-ahead log
+	return NewPipelineWithWriter(writer)
+}
+
+// NewPipelineWithWriter creates a new instance of Pipeline ingesting through a batched writer.
+func NewPipelineWithWriter(writer *indexer.Writer) *Pipeline {
+	return &Pipeline{
+		writer: writer,
+	}
+}
+
+// Add hands a document to the writer, which indexes it with its batch.
+func (p *Pipeline) Add(doc *crawler.Document) error {
+	return p.writer.Add(indexer.Document{
+		URL:         doc.URL,
+		Title:       doc.Title,
+		Description: doc.Metadata["description"],
+		Body:        doc.Text,
+		Date:        doc.Date,
+		Fields:      doc.Fields,
+	})
+}
+
+// UpdateDocument adds a fetched page, which is re-indexed with its batch if it changed.
+// Together with DeleteDocument it lets the pipeline serve as the index of a crawler.
+func (p *Pipeline) UpdateDocument(doc *crawler.Document) error {
+	return p.Add(doc)
+}
+
+// DeleteDocument drops the buffered copies of a page and removes it from the index.
+func (p *Pipeline) DeleteDocument(url string) error {
+	return p.writer.Delete(url)
+}
+
+// Flush commits the buffered documents, making them searchable.
+func (p *Pipeline) Flush() error {
+	return p.writer.Commit()
+}
@@ -1,12 +1,17 @@
 package search
 
 import (
+	"fmt"
+	"math"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/Mdromi/golang-search-engine/search-engine/analyzer"
 	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
-	"github.com/boltdb/bolt"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
 )
 
 // QueryProcessor is responsible for processing user queries.
@@ -20,13 +25,38 @@ const (
 
 // Searcher is responsible for searching the index and returning results.
 type Searcher struct {
-	db indexer.Database
+	db       storage.Store
+	analyzer *analyzer.Analyzer
+	schema   indexer.Schema
 }
 
 // SearchOptions represents the options for advanced search.
 type SearchOptions struct {
-	FilterDomain string // Filter results by a specific domain
-	SortBy       string // Sort results by "relevance" or "date"
+	FilterDomain string            // Filter results by a specific domain
+	SortBy       string            // Sort results by "relevance", "date" or a stored or sortable field ("-price" for descending)
+	Filters      map[string]string // Only return documents whose stored or indexed fields have these values or, if numeric or dates, values in these ranges
+}
+
+// FacetCount is the number of results having a value of a field.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// fieldFilter is a filter on the value of a field.
+type fieldFilter struct {
+	field string
+	expr  string // a value, or for numeric and date fields a range such as [1 TO 10] or <10
+}
+
+// fieldClause matches a field clause of a query, such as price:<100, category:"smart phones"
+// or published:[2023-01-01 TO 2023-12-31].
+var fieldClause = regexp.MustCompile(`(?:^|\s)([\w.-]+):(\[[^\]}]*[\]}]|\{[^\]}]*[\]}]|"[^"]*"|\S+)`)
+
+// queryTerm is a term looked up for a query and the weight of its matches.
+type queryTerm struct {
+	term  string
+	boost float64
 }
 
 // NewQueryProcessor creates a new instance of QueryProcessor.
@@ -35,42 +65,90 @@ func NewQueryProcessor() *QueryProcessor {
 }
 
 // NewSearcher creates a new instance of Searcher.
-func NewSearcher(db indexer.Database) *Searcher {
+func NewSearcher(db storage.Store) *Searcher {
 	return &Searcher{
-		db: db,
+		db:       db,
+		analyzer: analyzer.New(analyzer.Config{}),
 	}
 }
 
-// Process processes the user query and returns the individual keywords.
-func (qp *QueryProcessor) Process(query string) []string {
-	// Convert the query to lowercase
-	query = strings.ToLower(query)
+// SetAnalyzer selects the analyzer splitting queries into terms, which must be the one the index was written with.
+func (s *Searcher) SetAnalyzer(a *analyzer.Analyzer) {
+	s.analyzer = a
+}
 
-	// Split the query into words
-	words := strings.Fields(query)
+// SetSchema selects the typed fields the index was written with. Queries then match
+// the indexed text fields, weighted by their boosts, and filters, sorting and facets
+// use the indexed, sortable and facetable fields.
+func (s *Searcher) SetSchema(schema indexer.Schema) {
+	s.schema = schema
+}
+
+// SetCollection configures the searcher for the analyzer and schema of a collection.
+func (s *Searcher) SetCollection(config indexer.CollectionConfig) {
+	s.SetAnalyzer(analyzer.New(config.Analyzer))
+	s.SetSchema(config.Schema)
+}
 
-	// Return the processed words
-	return words
+// Process processes the user query and returns the individual keywords.
+func (qp *QueryProcessor) Process(query string) []string {
+	// Split the query into terms the same way documents are analyzed
+	return analyzer.Analyze(query)
 }
 
 // Search searches the index for the given query and returns matching URLs.
+// With a schema, clauses of its fields in the query, such as price:<100, filter
+// the results like the filters of the options.
 func (s *Searcher) Search(query string, options *SearchOptions) ([]string, error) {
-	// Process the query
-	words := NewQueryProcessor().Process(query)
+	typed := len(s.schema.Fields) > 0
+	var filters []fieldFilter
+	if typed {
+		for field, expr := range options.Filters {
+			filters = append(filters, fieldFilter{field: field, expr: expr})
+		}
+		sort.Slice(filters, func(i, j int) bool { return filters[i].field < filters[j].field })
 
-	// Open the read-only transaction
+		var clauses []fieldFilter
+		query, clauses = s.splitQuery(query)
+		filters = append(filters, clauses...)
+	}
+
+	// Process the query the same way the documents were analyzed
+	terms := s.queryTerms(query)
+
+	// Read a consistent view of the index
 	var results []string
-	err := s.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte("IndexBucket"))
+	scores := make(map[string]float64)
+	addResult := func(url string, score float64) {
+		if _, ok := scores[url]; !ok {
+			results = append(results, url)
+		}
+		scores[url] += score
+	}
 
-		// For each word, get the corresponding URLs from the index
-		for _, word := range words {
-			val := b.Get([]byte(word))
-			if val != nil {
-				urls := strings.Split(string(val), ",")
-				results = append(results, urls...)
+	err := s.db.View(func(r storage.Reader) error {
+		// For each term, get the corresponding documents from the postings lists
+		for _, term := range terms {
+			it := indexer.ReadPostings(r, term.term)
+			for it.Next() {
+				posting := it.Posting()
+				url, ok, err := indexer.LookupDocURL(r, posting.DocID)
+				if err != nil {
+					return err
+				}
+				if ok {
+					addResult(url, term.boost*float64(posting.Freq))
+				}
+			}
+			if err := it.Err(); err != nil {
+				return fmt.Errorf("failed to read postings of %q: %w", term.term, err)
 			}
 		}
+
+		// Filters alone select the documents matching them
+		if len(terms) == 0 && len(filters) > 0 {
+			return s.addFilterMatches(r, filters, func(url string) { addResult(url, 0) })
+		}
 		return nil
 	})
 	if err != nil {
@@ -82,82 +160,541 @@ func (s *Searcher) Search(query string, options *SearchOptions) ([]string, error
 		results = s.filterByDomain(results, options.FilterDomain)
 	}
 
+	// Apply filtering based on indexed or stored fields
+	switch {
+	case len(filters) > 0:
+		results, err = s.filterByTerms(results, filters)
+	case !typed && len(options.Filters) > 0:
+		results, err = s.filterByFields(results, options.Filters)
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	// Apply sorting
-	if options.SortBy == SortByDate {
-		results = s.sortByDate(results)
-	} else {
-		results = s.sortByRelevance(results)
+	switch options.SortBy {
+	case SortByDate:
+		results, err = s.sortByDate(results)
+	case SortByRelevance, "":
+		results = s.sortByRelevance(results, scores)
+	default:
+		results, err = s.sortByField(results, options.SortBy)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return results, nil
 }
 
-// filterByDomain filters the search results to include only URLs from a specific domain.
-func (s *Searcher) filterByDomain(results []string, domain string) []string {
+// filterByFields filters the search results to include only documents whose stored fields match the filters.
+func (s *Searcher) filterByFields(results []string, filters map[string]string) ([]string, error) {
 	var filteredResults []string
-	for _, url := range results {
-		if strings.Contains(url, domain) {
-			filteredResults = append(filteredResults, url)
+	err := s.db.View(func(r storage.Reader) error {
+		for _, url := range results {
+			doc, err := indexer.ReadDocumentByURL(r, url)
+			if err != nil {
+				return err
+			}
+
+			var fields map[string]interface{}
+			if doc != nil {
+				fields = doc.Fields
+			}
+
+			matches := true
+			for field, want := range filters {
+				if value, ok := fields[field]; !ok || !matchValue(value, want) {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				filteredResults = append(filteredResults, url)
+			}
 		}
-	}
-	return filteredResults
+		return nil
+	})
+	return filteredResults, err
 }
 
-// sortByRelevance sorts the search results by relevance (number of occurrences in the index).
-func (s *Searcher) sortByRelevance(results []string) []string {
-	// Count the occurrences of each URL in the results
-	counts := make(map[string]int)
-	for _, url := range results {
-		counts[url]++
+// sortByField sorts the search results by a stored or sortable field, ascending unless the field is
+// prefixed with "-". Documents without the field come last.
+func (s *Searcher) sortByField(results []string, field string) ([]string, error) {
+	descending := strings.HasPrefix(field, "-")
+	field = strings.TrimPrefix(field, "-")
+	if config, ok := s.schema.Field(field); len(s.schema.Fields) > 0 && (!ok || !config.Sortable) {
+		return nil, fmt.Errorf("field %s is not sortable", field)
+	}
+
+	values := make(map[string]interface{})
+	err := s.db.View(func(r storage.Reader) error {
+		for _, url := range results {
+			fields, err := s.fieldValues(r, url)
+			if err != nil {
+				return err
+			}
+			if len(fields[field]) > 0 {
+				values[url] = fields[field][0]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Sort the URLs based on their occurrences (relevance)
 	sort.SliceStable(results, func(i, j int) bool {
-		return counts[results[i]] > counts[results[j]]
+		a, aOK := values[results[i]]
+		b, bOK := values[results[j]]
+		if !aOK || !bOK {
+			return aOK && !bOK
+		}
+		if descending {
+			return lessValue(b, a)
+		}
+		return lessValue(a, b)
 	})
 
-	return results
+	return results, nil
 }
 
-// sortByDate sorts the search results by date (if applicable).
-func (s *Searcher) sortByDate(results []string) []string {
-	// Create a map to store the URLs and their corresponding dates
-	dateMap := make(map[string]string)
+// Facets counts the search results by the values of facetable or stored fields, most frequent values first.
+func (s *Searcher) Facets(results []string, fields ...string) (map[string][]FacetCount, error) {
+	for _, field := range fields {
+		if config, ok := s.schema.Field(field); len(s.schema.Fields) > 0 && (!ok || !config.Facetable) {
+			return nil, fmt.Errorf("field %s is not facetable", field)
+		}
+	}
+
+	counts := make(map[string]map[string]int)
+	for _, field := range fields {
+		counts[field] = make(map[string]int)
+	}
+	err := s.db.View(func(r storage.Reader) error {
+		seen := make(map[string]bool)
+		for _, url := range results {
+			if seen[url] {
+				continue
+			}
+			seen[url] = true
+
+			values, err := s.fieldValues(r, url)
+			if err != nil {
+				return err
+			}
+			for _, field := range fields {
+				for _, value := range values[field] {
+					counts[field][formatValue(value)]++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	// Extract and store the dates for each URL in the results
+	facets := make(map[string][]FacetCount, len(fields))
+	for field, values := range counts {
+		list := make([]FacetCount, 0, len(values))
+		for value, count := range values {
+			list = append(list, FacetCount{Value: value, Count: count})
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Count != list[j].Count {
+				return list[i].Count > list[j].Count
+			}
+			return list[i].Value < list[j].Value
+		})
+		facets[field] = list
+	}
+	return facets, nil
+}
+
+// fieldValues returns the values of the fields of a result: its sortable and facetable
+// fields with a schema, or its stored fields without one.
+func (s *Searcher) fieldValues(r storage.Reader, url string) (map[string][]interface{}, error) {
+	if len(s.schema.Fields) > 0 {
+		id, ok, err := indexer.LookupDocID(r, url)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return indexer.ReadDocValues(r, id, s.schema)
+	}
+
+	doc, err := indexer.ReadDocumentByURL(r, url)
+	if err != nil || doc == nil {
+		return nil, err
+	}
+	values := make(map[string][]interface{}, len(doc.Fields))
+	for field, value := range doc.Fields {
+		if list, ok := value.([]interface{}); ok {
+			values[field] = list
+		} else {
+			values[field] = []interface{}{value}
+		}
+	}
+	return values, nil
+}
+
+// queryTerms returns the terms a query is looked up by: its analyzed words without a schema,
+// or the words of every indexed text field, analyzed like the field and weighted by its boost.
+func (s *Searcher) queryTerms(query string) []queryTerm {
+	var terms []queryTerm
+	if len(s.schema.Fields) == 0 {
+		for _, word := range s.analyzer.Analyze(query) {
+			terms = append(terms, queryTerm{term: word, boost: 1})
+		}
+		return terms
+	}
+
+	for _, field := range s.schema.Fields {
+		if field.Type != indexer.TypeText || !field.Indexed {
+			continue
+		}
+		for _, word := range field.TextAnalyzer(s.analyzer).Analyze(query) {
+			terms = append(terms, queryTerm{term: indexer.FieldTerm(field.Name, word), boost: field.BoostFactor()})
+		}
+	}
+	return terms
+}
+
+// filterByTerms filters the search results to include only documents whose indexed fields match the filters.
+func (s *Searcher) filterByTerms(results []string, filters []fieldFilter) ([]string, error) {
+	matches := make(map[string]bool)
+	err := s.db.View(func(r storage.Reader) error {
+		return s.addFilterMatches(r, filters, func(url string) { matches[url] = true })
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var filteredResults []string
 	for _, url := range results {
-		date := extractDateFromURL(url)
-		if date != "" {
-			dateMap[url] = date
+		if matches[url] {
+			filteredResults = append(filteredResults, url)
+		}
+	}
+	return filteredResults, nil
+}
+
+// addFilterMatches calls add with the URL of every document whose indexed fields match the filters.
+// A text field matches if it has every analyzed word of the filter, a numeric or date field if it
+// has a value in the range of the filter, and other fields if they have its value.
+func (s *Searcher) addFilterMatches(r storage.Reader, filters []fieldFilter, add func(url string)) error {
+	// Collect the terms of which every match must have one per group
+	var groups [][]string
+	for _, filter := range filters {
+		field, ok := s.schema.Field(filter.field)
+		if !ok || !field.Indexed {
+			return fmt.Errorf("field %s is not indexed", filter.field)
+		}
+
+		switch field.Type {
+		case indexer.TypeText:
+			for _, word := range field.TextAnalyzer(s.analyzer).Analyze(filter.expr) {
+				groups = append(groups, []string{indexer.FieldTerm(field.Name, word)})
+			}
+		case indexer.TypeNumeric, indexer.TypeDate:
+			lo, hi, err := parseRange(field, filter.expr)
+			if err != nil {
+				return err
+			}
+			groups = append(groups, indexer.RangeTerms(field.Name, lo, hi))
+		default:
+			value, err := field.ParseValue(filter.expr)
+			if err != nil {
+				return err
+			}
+			groups = append(groups, []string{indexer.FieldTerm(field.Name, value)})
+		}
+	}
+
+	// Intersect the documents of the groups
+	var matches map[uint64]bool
+	for _, group := range groups {
+		docs := make(map[uint64]bool)
+		for _, term := range group {
+			it := indexer.ReadPostings(r, term)
+			for it.Next() {
+				if id := it.Posting().DocID; matches == nil || matches[id] {
+					docs[id] = true
+				}
+			}
+			if err := it.Err(); err != nil {
+				return fmt.Errorf("failed to read postings of %q: %w", term, err)
+			}
 		}
+		matches = docs
 	}
 
-	// Sort the results based on the extracted dates
-	sortedResults := make([]string, 0, len(dateMap))
-	for url := range dateMap {
-		sortedResults = append(sortedResults, url)
+	ids := make([]uint64, 0, len(matches))
+	for id := range matches {
+		ids = append(ids, id)
 	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		url, ok, err := indexer.LookupDocURL(r, id)
+		if err != nil {
+			return err
+		}
+		if ok {
+			add(url)
+		}
+	}
+	return nil
+}
 
-	return sortedResults
+// splitQuery takes the clauses of schema fields out of a query and returns the rest of the query and the clauses as filters.
+func (s *Searcher) splitQuery(query string) (string, []fieldFilter) {
+	var rest strings.Builder
+	var clauses []fieldFilter
+	last := 0
+	for _, match := range fieldClause.FindAllStringSubmatchIndex(query, -1) {
+		field := query[match[2]:match[3]]
+		if _, ok := s.schema.Field(field); !ok {
+			continue
+		}
+		rest.WriteString(query[last:match[0]])
+		rest.WriteString(" ")
+		last = match[1]
+		clauses = append(clauses, fieldFilter{field: field, expr: strings.Trim(query[match[4]:match[5]], `"`)})
+	}
+	rest.WriteString(query[last:])
+	return rest.String(), clauses
 }
 
-// extractDateFromURL is a helper function to extract the date from a URL (assuming the date format is "YYYY-MM-DD").
-func extractDateFromURL(url string) string {
-	// Check if the URL is long enough to contain the assumed date format
-	if len(url) < 27 {
-		return "" // Return an empty string if the URL is too short to contain the date
+// parseRange parses the filter of a numeric or date field into the inclusive range of the sortable
+// forms of the values it matches. The filter is a single value, a range such as [1 TO 10] with
+// inclusive ([]) or exclusive ({}) bounds and * for an open bound, or a comparison such as <10 or >=10.
+// A date without a time stands for the whole day, so [2023-01-01 TO 2023-12-31] includes December 31.
+// An empty range is returned with lo > hi.
+func parseRange(field indexer.FieldConfig, expr string) (lo, hi uint64, err error) {
+	// bound returns the sortable form of the first value of a day, or of its last if end is set
+	bound := func(value string, end bool) (uint64, error) {
+		value = strings.TrimSpace(value)
+		parsed, err := field.ParseValue(value)
+		if err != nil {
+			return 0, err
+		}
+		if date, ok := parsed.(time.Time); ok && end && len(value) == len("2006-01-02") {
+			sortable, _ := indexer.SortableValue(date.AddDate(0, 0, 1))
+			return sortable - 1, nil
+		}
+		sortable, _ := indexer.SortableValue(parsed)
+		return sortable, nil
+	}
+	// after returns the first sortable value after a bound, or reports that there is none
+	after := func(value uint64) (uint64, bool) {
+		return value + 1, value != math.MaxUint64
+	}
+	before := func(value uint64) (uint64, bool) {
+		return value - 1, value != 0
+	}
+	empty := func() (uint64, uint64, error) {
+		return 1, 0, nil
+	}
+
+	lo, hi = 0, math.MaxUint64
+	expr = strings.TrimSpace(expr)
+	var ok bool
+	switch {
+	case len(expr) >= 2 && strings.ContainsAny(expr[:1], "[{") && strings.ContainsAny(expr[len(expr)-1:], "]}"):
+		bounds := strings.SplitN(expr[1:len(expr)-1], " TO ", 2)
+		if len(bounds) != 2 {
+			return 0, 0, fmt.Errorf("field %s: invalid range %q", field.Name, expr)
+		}
+		if from := strings.TrimSpace(bounds[0]); from != "*" {
+			exclusive := expr[0] == '{'
+			if lo, err = bound(from, exclusive); err != nil {
+				return 0, 0, err
+			}
+			if exclusive {
+				if lo, ok = after(lo); !ok {
+					return empty()
+				}
+			}
+		}
+		if to := strings.TrimSpace(bounds[1]); to != "*" {
+			exclusive := expr[len(expr)-1] == '}'
+			if hi, err = bound(to, !exclusive); err != nil {
+				return 0, 0, err
+			}
+			if exclusive {
+				if hi, ok = before(hi); !ok {
+					return empty()
+				}
+			}
+		}
+	case strings.HasPrefix(expr, ">="):
+		lo, err = bound(expr[2:], false)
+	case strings.HasPrefix(expr, ">"):
+		if lo, err = bound(expr[1:], true); err == nil {
+			if lo, ok = after(lo); !ok {
+				return empty()
+			}
+		}
+	case strings.HasPrefix(expr, "<="):
+		hi, err = bound(expr[2:], true)
+	case strings.HasPrefix(expr, "<"):
+		if hi, err = bound(expr[1:], false); err == nil {
+			if hi, ok = before(hi); !ok {
+				return empty()
+			}
+		}
+	default:
+		if lo, err = bound(expr, false); err == nil {
+			hi, err = bound(expr, true)
+		}
 	}
+	return lo, hi, err
+}
 
-	// Extract the date from the URL based on the assumed format
-	date := url[17:27]
+// matchValue reports whether a stored field value, or any of its values if it is multi-valued, equals want.
+func matchValue(value interface{}, want string) bool {
+	if values, ok := value.([]interface{}); ok {
+		for _, v := range values {
+			if fmt.Sprint(v) == want {
+				return true
+			}
+		}
+		return false
+	}
+	return fmt.Sprint(value) == want
+}
 
-	// Validate the extracted date format using a regular expression
-	dateRegex := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
-	if !dateRegex.MatchString(date) {
-		return "" // Return an empty string if the date format is not correct
+// lessValue compares two field values, numerically if both are numbers and chronologically if both are dates.
+func lessValue(a, b interface{}) bool {
+	aNum, aOK := a.(float64)
+	bNum, bOK := b.(float64)
+	if aOK && bOK {
+		return aNum < bNum
+	}
+	aTime, aOK := a.(time.Time)
+	bTime, bOK := b.(time.Time)
+	if aOK && bOK {
+		return aTime.Before(bTime)
 	}
+	return formatValue(a) < formatValue(b)
+}
 
-	// Additional validation can be added here if needed
+// formatValue formats a field value for display and comparison.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
 
-	return date
+// SearchDocuments searches the index like Search and returns the stored documents of the results for display.
+// Results without a stored document are returned with only their URL.
+func (s *Searcher) SearchDocuments(query string, options *SearchOptions) ([]indexer.StoredDocument, error) {
+	urls, err := s.Search(query, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []indexer.StoredDocument
+	seen := make(map[string]bool)
+	err = s.db.View(func(r storage.Reader) error {
+		for _, url := range urls {
+			if seen[url] {
+				continue
+			}
+			seen[url] = true
+
+			doc, err := indexer.ReadDocumentByURL(r, url)
+			if err != nil {
+				return err
+			}
+			if doc == nil {
+				doc = &indexer.StoredDocument{URL: url}
+			}
+			if doc.URL == "" {
+				doc.URL = url
+			}
+			docs = append(docs, *doc)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// filterByDomain filters the search results to include only URLs from a specific domain.
+func (s *Searcher) filterByDomain(results []string, domain string) []string {
+	var filteredResults []string
+	for _, url := range results {
+		if strings.Contains(url, domain) {
+			filteredResults = append(filteredResults, url)
+		}
+	}
+	return filteredResults
+}
+
+// sortByRelevance sorts the search results by relevance (total term frequency of the query words,
+// weighted by the boosts of the fields they matched in).
+func (s *Searcher) sortByRelevance(results []string, scores map[string]float64) []string {
+	// Sort the URLs based on their scores (relevance)
+	sort.SliceStable(results, func(i, j int) bool {
+		return scores[results[i]] > scores[results[j]]
+	})
+
+	return results
+}
+
+// sortByDate sorts the search results by the dates of their documents, newest first.
+// Results without a date come last.
+func (s *Searcher) sortByDate(results []string) ([]string, error) {
+	dates := make(map[string]time.Time)
+	err := s.db.View(func(r storage.Reader) error {
+		for _, url := range results {
+			date, err := s.documentDate(r, url)
+			if err != nil {
+				return err
+			}
+			dates[url] = date
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := dates[results[i]], dates[results[j]]
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.After(b)
+	})
+
+	return results, nil
+}
+
+// documentDate returns the date of a result: its sortable date field if the schema declares one,
+// or the date kept in the document store.
+func (s *Searcher) documentDate(r storage.Reader, url string) (time.Time, error) {
+	if field, ok := s.schema.Field(indexer.FieldDate); ok && field.Sortable {
+		values, err := s.fieldValues(r, url)
+		if err != nil || len(values[indexer.FieldDate]) == 0 {
+			return time.Time{}, err
+		}
+		date, _ := values[indexer.FieldDate][0].(time.Time)
+		return date, nil
+	}
+
+	doc, err := indexer.ReadDocumentByURL(r, url)
+	if err != nil || doc == nil {
+		return time.Time{}, err
+	}
+	return doc.Date, nil
 }
@@ -0,0 +1,362 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshot format written by WriteSnapshot.
+const SnapshotVersion = 1
+
+// ManifestFile is the name of the manifest in a snapshot directory.
+const ManifestFile = "manifest.json"
+
+// Constants for the sizes of snapshot chunks, before compression
+const (
+	minChunkBytes = 1 << 20
+	maxChunkBytes = 8 << 20
+	chunkBoundary = 64 // a key ends a chunk past minChunkBytes with a chance of 1 in chunkBoundary
+	maxFieldBytes = 1 << 30
+)
+
+// ErrChecksumMismatch is returned when a snapshot chunk does not match its checksum.
+var ErrChecksumMismatch = errors.New("storage: snapshot checksum mismatch")
+
+// Manifest describes a snapshot. It is written last, so a snapshot without
+// a manifest is incomplete.
+type Manifest struct {
+	Version int         `json:"version"`
+	Created time.Time   `json:"created"`
+	Base    string      `json:"base,omitempty"` // snapshot holding the chunks not stored here, relative to this one
+	Entries int         `json:"entries"`
+	Bytes   int64       `json:"bytes"` // size of the keys and values
+	Chunks  []ChunkInfo `json:"chunks"`
+}
+
+// ChunkInfo describes a chunk of a snapshot, a compressed run of adjacent keys.
+type ChunkInfo struct {
+	SHA256  string `json:"sha256"` // checksum of the compressed chunk, which is also its file name
+	Entries int    `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+	Base    bool   `json:"base,omitempty"` // the chunk is unchanged and kept by the base snapshot
+}
+
+// WriteSnapshot writes the keys of a snapshot into dir, which must not exist yet.
+// Chunk boundaries depend on the keys only, so runs of keys unchanged since the
+// base snapshot make identical chunks. If base names a snapshot, those chunks are
+// not written again but referred to, making the snapshot incremental.
+func WriteSnapshot(r Reader, dir, base string) (*Manifest, error) {
+	manifest := &Manifest{Version: SnapshotVersion, Created: time.Now().UTC()}
+
+	// Collect the chunks already kept by the base snapshot
+	inBase := make(map[string]bool)
+	if base != "" {
+		baseManifest, err := ReadManifest(base)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read base snapshot: %w", err)
+		}
+		for _, chunk := range baseManifest.Chunks {
+			inBase[chunk.SHA256] = true
+		}
+		if manifest.Base, err = relativePath(dir, base); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	var chunk chunkWriter
+	flush := func() error {
+		info, data, err := chunk.finish()
+		if err != nil || info.Entries == 0 {
+			return err
+		}
+		if inBase[info.SHA256] {
+			info.Base = true
+		} else if err := writeFileSync(filepath.Join(dir, info.SHA256), data); err != nil {
+			return err
+		}
+		manifest.Chunks = append(manifest.Chunks, info)
+		return nil
+	}
+
+	err := r.Scan(nil, nil, func(key, value []byte) error {
+		chunk.add(key, value)
+		manifest.Entries++
+		manifest.Bytes += int64(len(key) + len(value))
+		if chunk.full(key) {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The manifest makes the snapshot complete
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	tmp := filepath.Join(dir, ManifestFile+".tmp")
+	if err := writeFileSync(tmp, data); err != nil {
+		return nil, err
+	}
+	return manifest, os.Rename(tmp, filepath.Join(dir, ManifestFile))
+}
+
+// ReadManifest reads the manifest of the snapshot in dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest in %s: %w", dir, err)
+	}
+	if manifest.Version != SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d in %s", manifest.Version, dir)
+	}
+	return &manifest, nil
+}
+
+// VerifySnapshot checks that every chunk of the snapshot in dir, including those kept
+// by its base snapshots, exists and matches its checksum.
+func VerifySnapshot(dir string) (*Manifest, error) {
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, chunk := range manifest.Chunks {
+		if _, err := readChunk(dir, manifest, chunk); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// RestoreSnapshot writes the keys of the snapshot in dir into an empty store, one batch per chunk.
+// Every chunk is verified against its checksum before any is written, but a restore failing
+// halfway leaves the chunks written so far behind.
+func RestoreSnapshot(s Store, dir string) (*Manifest, error) {
+	manifest, err := VerifySnapshot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse to mix the snapshot with existing keys
+	empty := true
+	err = s.View(func(r Reader) error {
+		return r.Scan(nil, nil, func(_, _ []byte) error {
+			empty = false
+			return errStopScan
+		})
+	})
+	if err != nil && err != errStopScan {
+		return nil, err
+	}
+	if !empty {
+		return nil, errors.New("storage: cannot restore into a store that is not empty")
+	}
+
+	for _, chunk := range manifest.Chunks {
+		data, err := readChunk(dir, manifest, chunk)
+		if err != nil {
+			return nil, err
+		}
+		err = s.Update(func(w Writer) error {
+			return decodeChunk(data, w.Put)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore chunk %s: %w", chunk.SHA256, err)
+		}
+	}
+	return manifest, nil
+}
+
+// errStopScan ends a scan early.
+var errStopScan = errors.New("stop scan")
+
+// readChunk reads a chunk from the snapshot keeping it and verifies its checksum.
+func readChunk(dir string, manifest *Manifest, chunk ChunkInfo) ([]byte, error) {
+	// Follow the base snapshots to the one holding the chunk
+	for chunk.Base {
+		if manifest.Base == "" {
+			return nil, fmt.Errorf("chunk %s is kept by a base snapshot, but %s has none", chunk.SHA256, dir)
+		}
+		dir = filepath.Join(dir, manifest.Base)
+
+		var err error
+		if manifest, err = ReadManifest(dir); err != nil {
+			return nil, fmt.Errorf("failed to read base snapshot: %w", err)
+		}
+		found := false
+		for _, c := range manifest.Chunks {
+			if c.SHA256 == chunk.SHA256 {
+				chunk, found = c, true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("chunk %s is missing from base snapshot %s", chunk.SHA256, dir)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, chunk.SHA256))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != chunk.SHA256 {
+		return nil, fmt.Errorf("%w: chunk %s in %s", ErrChecksumMismatch, chunk.SHA256, dir)
+	}
+	return data, nil
+}
+
+// chunkWriter encodes a run of keys as a chunk: gzip-compressed records of a uvarint
+// key length, the key, a uvarint value length and the value.
+type chunkWriter struct {
+	buf     bytes.Buffer
+	entries int
+	bytes   int64
+}
+
+// add appends a key and its value to the chunk.
+func (c *chunkWriter) add(key, value []byte) {
+	var header [binary.MaxVarintLen64]byte
+	c.buf.Write(header[:binary.PutUvarint(header[:], uint64(len(key)))])
+	c.buf.Write(key)
+	c.buf.Write(header[:binary.PutUvarint(header[:], uint64(len(value)))])
+	c.buf.Write(value)
+	c.entries++
+	c.bytes += int64(len(key) + len(value))
+}
+
+// full reports whether the chunk should end after key.
+func (c *chunkWriter) full(key []byte) bool {
+	if c.buf.Len() >= maxChunkBytes {
+		return true
+	}
+	if c.buf.Len() < minChunkBytes {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return h.Sum32()%chunkBoundary == 0
+}
+
+// finish compresses the chunk and starts a new one.
+func (c *chunkWriter) finish() (ChunkInfo, []byte, error) {
+	info := ChunkInfo{Entries: c.entries, Bytes: c.bytes}
+	if c.entries == 0 {
+		return info, nil, nil
+	}
+
+	var compressed bytes.Buffer
+	gzw := gzip.NewWriter(&compressed)
+	if _, err := gzw.Write(c.buf.Bytes()); err != nil {
+		return info, nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return info, nil, err
+	}
+	sum := sha256.Sum256(compressed.Bytes())
+	info.SHA256 = hex.EncodeToString(sum[:])
+
+	c.buf.Reset()
+	c.entries = 0
+	c.bytes = 0
+	return info, compressed.Bytes(), nil
+}
+
+// decodeChunk calls put for every key and value of a chunk.
+func decodeChunk(data []byte, put func(key, value []byte) error) error {
+	gzr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+
+	r := bufio.NewReader(gzr)
+	for {
+		key, err := readRecordField(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		value, err := readRecordField(r)
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		if err := put(key, value); err != nil {
+			return err
+		}
+	}
+}
+
+// readRecordField reads a length-prefixed field of a chunk record.
+func readRecordField(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxFieldBytes {
+		return nil, errors.New("storage: corrupt snapshot chunk")
+	}
+	field := make([]byte, length)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return field, nil
+}
+
+// relativePath returns the path of target relative to dir.
+func relativePath(dir, target string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(absDir, absTarget)
+}
+
+// writeFileSync writes a file and flushes it to disk.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
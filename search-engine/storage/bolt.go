@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bytes"
+
+	"github.com/boltdb/bolt"
+)
+
+// boltBucket is the bucket holding the keys of a BoltStore.
+var boltBucket = []byte("kv")
+
+// BoltStore is a Store kept in a BoltDB file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens or creates the BoltDB file at path as a Store.
+func OpenBolt(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0666, &bolt.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return NewBoltStore(db)
+}
+
+// NewBoltStore creates a Store in an open BoltDB.
+func NewBoltStore(db *bolt.DB) (*BoltStore, error) {
+	// Only write to files without the bucket, so opening an index leaves it untouched
+	var exists bool
+	err := db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(boltBucket) != nil
+		return nil
+	})
+	if err == nil && !exists {
+		err = db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(boltBucket)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// View reads a consistent view of the store in a read-only transaction.
+func (s *BoltStore) View(fn func(Reader) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return fn(boltReader{tx.Bucket(boltBucket)})
+	})
+}
+
+// Update applies the writes of fn in a single writable transaction.
+func (s *BoltStore) Update(fn func(Writer) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(boltWriter{boltReader{tx.Bucket(boltBucket)}})
+	})
+}
+
+// UpdateBolt is Update with access to the BoltDB transaction, to move data kept
+// outside the store into it atomically.
+func (s *BoltStore) UpdateBolt(fn func(tx *bolt.Tx, w Writer) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(tx, boltWriter{boltReader{tx.Bucket(boltBucket)}})
+	})
+}
+
+// Snapshot opens a read-only transaction that lasts until the snapshot is released.
+// Long-lived snapshots keep BoltDB from reusing the pages they read.
+func (s *BoltStore) Snapshot() (Snapshot, error) {
+	tx, err := s.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	return boltSnapshot{boltReader{tx.Bucket(boltBucket)}, tx}, nil
+}
+
+// Close closes the BoltDB file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// boltReader reads the store bucket within a transaction.
+type boltReader struct {
+	bucket *bolt.Bucket
+}
+
+func (r boltReader) Get(key []byte) ([]byte, error) {
+	return r.bucket.Get(key), nil
+}
+
+func (r boltReader) Scan(start, end []byte, fn func(key, value []byte) error) error {
+	c := r.bucket.Cursor()
+	for key, value := c.Seek(start); key != nil && (end == nil || bytes.Compare(key, end) < 0); key, value = c.Next() {
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r boltReader) ScanPrefix(prefix []byte, fn func(key, value []byte) error) error {
+	return r.Scan(prefix, PrefixEnd(prefix), fn)
+}
+
+// boltWriter modifies the store bucket within a writable transaction.
+type boltWriter struct {
+	boltReader
+}
+
+func (w boltWriter) Put(key, value []byte) error {
+	// BoltDB keeps an empty value apart from a missing one only if it is not nil
+	if value == nil {
+		value = []byte{}
+	}
+	return w.bucket.Put(key, value)
+}
+
+func (w boltWriter) Delete(key []byte) error {
+	return w.bucket.Delete(key)
+}
+
+// boltSnapshot is a Snapshot backed by a read-only transaction.
+type boltSnapshot struct {
+	boltReader
+	tx *bolt.Tx
+}
+
+func (s boltSnapshot) Release() error {
+	return s.tx.Rollback()
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// LevelDBStore is a Store kept in a LevelDB directory. LevelDB is a log-structured
+// merge tree: writes are appended to a log and sorted in the background, so heavy
+// write loads do not wait on rewriting B+tree pages as they do with BoltDB.
+type LevelDBStore struct {
+	mutex sync.Mutex // serializes updates, so their reads stay consistent with their writes
+	db    *leveldb.DB
+}
+
+// OpenLevelDB opens or creates the LevelDB directory at path as a Store.
+func OpenLevelDB(path string) (*LevelDBStore, error) {
+	db, err := leveldb.OpenFile(path, &opt.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDBStore{db: db}, nil
+}
+
+// View reads a LevelDB snapshot.
+func (s *LevelDBStore) View(fn func(Reader) error) error {
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	return fn(levelReader{snapshot})
+}
+
+// Update buffers the writes of fn over a snapshot and writes them as one synced LevelDB batch.
+func (s *LevelDBStore) Update(fn func(Writer) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	batch := newOverlay(levelReader{snapshot})
+	if err := fn(batch); err != nil {
+		return err
+	}
+	if len(batch.writes) == 0 {
+		return nil
+	}
+
+	writes := new(leveldb.Batch)
+	for _, entry := range batch.writes {
+		if entry.value == nil {
+			writes.Delete(entry.key)
+		} else {
+			writes.Put(entry.key, entry.value)
+		}
+	}
+	return s.db.Write(writes, &opt.WriteOptions{Sync: true})
+}
+
+// Snapshot returns a LevelDB snapshot, which must be released.
+func (s *LevelDBStore) Snapshot() (Snapshot, error) {
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return levelSnapshot{levelReader{snapshot}, snapshot}, nil
+}
+
+// Close closes the LevelDB directory.
+func (s *LevelDBStore) Close() error {
+	return s.db.Close()
+}
+
+// levelSource is the read API shared by LevelDB databases and snapshots.
+type levelSource interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+// levelReader reads a LevelDB snapshot.
+type levelReader struct {
+	source levelSource
+}
+
+func (r levelReader) Get(key []byte) ([]byte, error) {
+	value, err := r.source.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err == nil && value == nil {
+		// LevelDB reads empty values back as nil, which would read as missing keys
+		value = []byte{}
+	}
+	return value, err
+}
+
+func (r levelReader) Scan(start, end []byte, fn func(key, value []byte) error) error {
+	it := r.source.NewIterator(&util.Range{Start: start, Limit: end}, nil)
+	defer it.Release()
+
+	for it.Next() {
+		// Iterators reuse their buffers, while readers promise values for the whole view
+		key := append([]byte{}, it.Key()...)
+		value := append([]byte{}, it.Value()...)
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+func (r levelReader) ScanPrefix(prefix []byte, fn func(key, value []byte) error) error {
+	return r.Scan(prefix, PrefixEnd(prefix), fn)
+}
+
+// levelSnapshot is a Snapshot backed by a LevelDB snapshot.
+type levelSnapshot struct {
+	levelReader
+	snapshot *leveldb.Snapshot
+}
+
+func (s levelSnapshot) Release() error {
+	s.snapshot.Release()
+	return nil
+}
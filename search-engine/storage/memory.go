@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrClosed is returned when using a closed store.
+var ErrClosed = errors.New("storage: store is closed")
+
+// MemoryStore is a Store held in memory, for tests and short-lived indexes.
+// Its keys are kept in an immutable sorted slice, so views and snapshots are free
+// and each Update copies the slice once when it commits.
+type MemoryStore struct {
+	mutex  sync.Mutex // serializes updates
+	data   *memData
+	closed bool
+	lock   sync.RWMutex // guards data and closed
+}
+
+// memData is an immutable sorted set of entries.
+type memData struct {
+	entries []memEntry
+}
+
+// memEntry is a single key of a MemoryStore.
+type memEntry struct {
+	key   []byte
+	value []byte
+}
+
+// NewMemoryStore creates a new instance of MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: &memData{}}
+}
+
+// View reads the current entries of the store.
+func (s *MemoryStore) View(fn func(Reader) error) error {
+	data, err := s.current()
+	if err != nil {
+		return err
+	}
+	return fn(data)
+}
+
+// Update buffers the writes of fn and applies them at once if it succeeds.
+func (s *MemoryStore) Update(fn func(Writer) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := s.current()
+	if err != nil {
+		return err
+	}
+
+	batch := newOverlay(data)
+	if err := fn(batch); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	s.data = data.commit(batch)
+	return nil
+}
+
+// Snapshot returns the current entries of the store, which later updates leave untouched.
+func (s *MemoryStore) Snapshot() (Snapshot, error) {
+	data, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return memSnapshot{data}, nil
+}
+
+// Close drops the entries of the store.
+func (s *MemoryStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	s.data = nil
+	return nil
+}
+
+// current returns the entries of the store.
+func (s *MemoryStore) current() (*memData, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return nil, ErrClosed
+	}
+	return s.data, nil
+}
+
+// search returns the index of the first entry not before key.
+func (d *memData) search(key []byte) int {
+	return sort.Search(len(d.entries), func(i int) bool {
+		return bytes.Compare(d.entries[i].key, key) >= 0
+	})
+}
+
+func (d *memData) Get(key []byte) ([]byte, error) {
+	i := d.search(key)
+	if i < len(d.entries) && bytes.Equal(d.entries[i].key, key) {
+		return d.entries[i].value, nil
+	}
+	return nil, nil
+}
+
+func (d *memData) Scan(start, end []byte, fn func(key, value []byte) error) error {
+	for i := d.search(start); i < len(d.entries) && inRange(d.entries[i].key, start, end); i++ {
+		if err := fn(d.entries[i].key, d.entries[i].value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *memData) ScanPrefix(prefix []byte, fn func(key, value []byte) error) error {
+	return d.Scan(prefix, PrefixEnd(prefix), fn)
+}
+
+// memSnapshot is a Snapshot of a MemoryStore.
+type memSnapshot struct {
+	*memData
+}
+
+func (memSnapshot) Release() error {
+	return nil
+}
+
+// commit returns the entries of a MemoryStore with the writes of a batch applied.
+func (d *memData) commit(batch *overlay) *memData {
+	if len(batch.writes) == 0 {
+		return d
+	}
+
+	data := &memData{entries: make([]memEntry, 0, len(d.entries)+len(batch.writes))}
+	// Scan only fails when its callback does, which this one never does
+	_ = batch.Scan(nil, nil, func(key, value []byte) error {
+		data.entries = append(data.entries, memEntry{key: key, value: value})
+		return nil
+	})
+	return data
+}
@@ -0,0 +1,100 @@
+package storage
+
+// NamespaceStore is a Store whose keys are kept under a prefix of another store,
+// so several indexes can share one file. Its keys are seen without the prefix.
+type NamespaceStore struct {
+	store  Store
+	prefix []byte
+}
+
+// Namespace returns a Store holding the keys of store under prefix. Closing it
+// leaves store open.
+func Namespace(store Store, prefix string) *NamespaceStore {
+	return &NamespaceStore{store: store, prefix: []byte(prefix)}
+}
+
+// View reads a consistent view of the namespace.
+func (s *NamespaceStore) View(fn func(Reader) error) error {
+	return s.store.View(func(r Reader) error {
+		return fn(namespaceReader{r, s.prefix})
+	})
+}
+
+// Update applies the writes of fn to the namespace as one atomic batch.
+func (s *NamespaceStore) Update(fn func(Writer) error) error {
+	return s.store.Update(func(w Writer) error {
+		return fn(namespaceWriter{namespaceReader{w, s.prefix}, w})
+	})
+}
+
+// Snapshot returns a point-in-time view of the namespace.
+func (s *NamespaceStore) Snapshot() (Snapshot, error) {
+	snapshot, err := s.store.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return namespaceSnapshot{namespaceReader{snapshot, s.prefix}, snapshot}, nil
+}
+
+// Close does nothing, as the underlying store is shared.
+func (s *NamespaceStore) Close() error {
+	return nil
+}
+
+// namespaceReader reads the keys of a namespace, stripping the prefix.
+type namespaceReader struct {
+	r      Reader
+	prefix []byte
+}
+
+// key returns the key of the underlying store.
+func (r namespaceReader) key(key []byte) []byte {
+	return append(append(make([]byte, 0, len(r.prefix)+len(key)), r.prefix...), key...)
+}
+
+func (r namespaceReader) Get(key []byte) ([]byte, error) {
+	return r.r.Get(r.key(key))
+}
+
+func (r namespaceReader) Scan(start, end []byte, fn func(key, value []byte) error) error {
+	// An unbounded scan ends with the namespace
+	var storeEnd []byte
+	if end == nil {
+		storeEnd = PrefixEnd(r.prefix)
+	} else {
+		storeEnd = r.key(end)
+	}
+	return r.r.Scan(r.key(start), storeEnd, func(key, value []byte) error {
+		return fn(key[len(r.prefix):], value)
+	})
+}
+
+func (r namespaceReader) ScanPrefix(prefix []byte, fn func(key, value []byte) error) error {
+	return r.r.ScanPrefix(r.key(prefix), func(key, value []byte) error {
+		return fn(key[len(r.prefix):], value)
+	})
+}
+
+// namespaceWriter modifies the keys of a namespace.
+type namespaceWriter struct {
+	namespaceReader
+	w Writer
+}
+
+func (w namespaceWriter) Put(key, value []byte) error {
+	return w.w.Put(w.key(key), value)
+}
+
+func (w namespaceWriter) Delete(key []byte) error {
+	return w.w.Delete(w.key(key))
+}
+
+// namespaceSnapshot is a Snapshot of a namespace.
+type namespaceSnapshot struct {
+	namespaceReader
+	snapshot Snapshot
+}
+
+func (s namespaceSnapshot) Release() error {
+	return s.snapshot.Release()
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"bytes"
+	"sort"
+)
+
+// overlay buffers the writes of a batch over a view of a store, so the batch reads its own
+// writes, for stores that apply a batch only once it is complete.
+type overlay struct {
+	base   Reader
+	writes map[string]*memEntry // a nil value marks a deleted key
+}
+
+// newOverlay creates an empty batch over a view of a store.
+func newOverlay(base Reader) *overlay {
+	return &overlay{base: base, writes: make(map[string]*memEntry)}
+}
+
+func (o *overlay) Get(key []byte) ([]byte, error) {
+	if entry, ok := o.writes[string(key)]; ok {
+		return entry.value, nil
+	}
+	return o.base.Get(key)
+}
+
+func (o *overlay) Scan(start, end []byte, fn func(key, value []byte) error) error {
+	// Merge the buffered writes in the range into the scan of the view
+	written := o.sorted(start, end)
+	emit := func(entry *memEntry) error {
+		if entry.value == nil {
+			return nil
+		}
+		return fn(entry.key, entry.value)
+	}
+
+	err := o.base.Scan(start, end, func(key, value []byte) error {
+		for len(written) > 0 && bytes.Compare(written[0].key, key) < 0 {
+			if err := emit(written[0]); err != nil {
+				return err
+			}
+			written = written[1:]
+		}
+		if len(written) > 0 && bytes.Equal(written[0].key, key) {
+			entry := written[0]
+			written = written[1:]
+			return emit(entry)
+		}
+		return fn(key, value)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range written {
+		if err := emit(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *overlay) ScanPrefix(prefix []byte, fn func(key, value []byte) error) error {
+	return o.Scan(prefix, PrefixEnd(prefix), fn)
+}
+
+func (o *overlay) Put(key, value []byte) error {
+	// Copy the arguments, as callers may reuse them
+	o.writes[string(key)] = &memEntry{key: append([]byte{}, key...), value: append([]byte{}, value...)}
+	return nil
+}
+
+func (o *overlay) Delete(key []byte) error {
+	o.writes[string(key)] = &memEntry{key: append([]byte{}, key...)}
+	return nil
+}
+
+// sorted returns the buffered writes in [start, end) in key order.
+func (o *overlay) sorted(start, end []byte) []*memEntry {
+	var written []*memEntry
+	for _, entry := range o.writes {
+		if inRange(entry.key, start, end) {
+			written = append(written, entry)
+		}
+	}
+	sort.Slice(written, func(i, j int) bool {
+		return bytes.Compare(written[i].key, written[j].key) < 0
+	})
+	return written
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Constants for the storage engines
+const (
+	EngineBolt    = "bolt"
+	EngineLevelDB = "leveldb"
+	EngineMemory  = "memory"
+)
+
+// Reader reads a consistent view of a store. Keys and values passed to callers are
+// only valid until the end of the View, Update or Snapshot they were read in, and must
+// not be modified.
+type Reader interface {
+	// Get returns the value of a key, or nil if it does not exist.
+	Get(key []byte) ([]byte, error)
+
+	// Scan calls fn for every key in [start, end) in ascending order. A nil end scans to the last key.
+	Scan(start, end []byte, fn func(key, value []byte) error) error
+
+	// ScanPrefix calls fn for every key starting with prefix in ascending order.
+	ScanPrefix(prefix []byte, fn func(key, value []byte) error) error
+}
+
+// Writer modifies a store within a batch. It reads its own writes.
+// The store must not be modified from within a scan callback.
+type Writer interface {
+	Reader
+
+	// Put sets the value of a key.
+	Put(key, value []byte) error
+
+	// Delete removes a key. Deleting a missing key is not an error.
+	Delete(key []byte) error
+}
+
+// Snapshot is a long-lived, point-in-time view of a store.
+type Snapshot interface {
+	Reader
+
+	// Release frees the snapshot.
+	Release() error
+}
+
+// Store is an ordered key-value store.
+type Store interface {
+	// View reads a consistent view of the store.
+	View(fn func(Reader) error) error
+
+	// Update applies the writes of fn as one atomic batch, or none of them if fn returns an error.
+	Update(fn func(Writer) error) error
+
+	// Snapshot returns a point-in-time view of the store, which must be released.
+	Snapshot() (Snapshot, error)
+
+	// Close closes the store.
+	Close() error
+}
+
+// Open opens the store of an engine at path: a BoltDB file, a LevelDB directory,
+// or nothing for the memory engine.
+func Open(engine, path string) (Store, error) {
+	switch engine {
+	case EngineBolt, "":
+		return OpenBolt(path)
+	case EngineLevelDB:
+		return OpenLevelDB(path)
+	case EngineMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage engine %q", engine)
+	}
+}
+
+// PrefixEnd returns the first key after every key starting with prefix,
+// or nil if there is none.
+func PrefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// inRange reports whether a key is in [start, end), with a nil end being unbounded.
+func inRange(key, start, end []byte) bool {
+	return bytes.Compare(key, start) >= 0 && (end == nil || bytes.Compare(key, end) < 0)
+}
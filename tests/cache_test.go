@@ -0,0 +1,124 @@
+package main_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestQueryCache tests that cached query results are invalidated by writes to the index.
+func TestQueryCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+
+	db, err := storage.OpenBolt(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, indexer.NewRedisCache(redisClient))
+	idx.SetCacheConfig(indexer.CacheConfig{Namespace: "test", TTL: time.Hour, NegativeTTL: time.Minute})
+	assert.NoError(t, idx.IndexDocuments([]indexer.Document{{URL: URL1, Body: "phones"}}))
+
+	generation, err := idx.Generation()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), generation)
+
+	// The first query fills the cache, the second is served from it
+	for n := 0; n < 2; n++ {
+		urls, err := idx.Query("phones")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{URL1}, urls)
+	}
+	assert.True(t, mr.Exists("test:1:query:phones"))
+	assert.Equal(t, time.Hour, mr.TTL("test:1:query:phones"))
+
+	// Empty results are cached for the negative TTL
+	for n := 0; n < 2; n++ {
+		urls, err := idx.Query("tablets")
+		assert.NoError(t, err)
+		assert.Empty(t, urls)
+	}
+	assert.Equal(t, time.Minute, mr.TTL("test:1:query:tablets"))
+
+	// Updates and deletions start a new generation, so no stale results are served
+	changed, err := idx.UpdateDocument(indexer.Document{URL: URL1, Body: "tablets"})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	urls, err := idx.Query("tablets")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL1}, urls)
+
+	_, err = idx.DeleteDocument(URL1)
+	assert.NoError(t, err)
+	urls, err = idx.Query("tablets")
+	assert.NoError(t, err)
+	assert.Empty(t, urls)
+
+	assert.Equal(t, indexer.CacheStats{Hits: 2, NegativeHits: 1, Misses: 4, HitRatio: 2.0 / 6}, idx.CacheStats())
+}
+
+// TestLRUCache tests size-bounded eviction and expiry of the in-process cache.
+func TestLRUCache(t *testing.T) {
+	ctx := context.Background()
+	cache := indexer.NewLRUCache(10)
+
+	assert.NoError(t, cache.Set(ctx, "a", []byte("1234"), 0))
+	assert.NoError(t, cache.Set(ctx, "b", []byte("1234"), 0))
+	_, found, err := cache.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, found)
+
+	// "b" is the least recently used entry
+	assert.NoError(t, cache.Set(ctx, "c", []byte("1234"), 0))
+	_, found, _ = cache.Get(ctx, "b")
+	assert.False(t, found)
+	assert.Equal(t, 2, cache.Len())
+
+	assert.NoError(t, cache.Set(ctx, "big", make([]byte, 100), 0))
+	_, found, _ = cache.Get(ctx, "big")
+	assert.False(t, found)
+
+	assert.NoError(t, cache.Set(ctx, "d", []byte("1"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	_, found, _ = cache.Get(ctx, "d")
+	assert.False(t, found)
+}
+
+// TestTieredCache tests that the near cache is filled from the far cache.
+func TestTieredCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	near := indexer.NewLRUCache(1 << 10)
+	cache := indexer.NewTieredCache(near, indexer.NewRedisCache(redisClient), time.Minute)
+
+	assert.NoError(t, mr.Set("shared", "value"))
+	value, found, err := cache.Get(ctx, "shared")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("value"), value)
+	assert.Equal(t, 1, near.Len())
+
+	// The near cache answers without Redis
+	mr.Close()
+	value, found, err = cache.Get(ctx, "shared")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("value"), value)
+
+	_, err = indexer.NewCache(indexer.CacheConfig{Type: indexer.CacheTiered}, nil)
+	assert.Error(t, err)
+	none, err := indexer.NewCache(indexer.CacheConfig{Type: indexer.CacheNone}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, none)
+}
@@ -0,0 +1,84 @@
+package main_test
+
+import (
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/analyzer"
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCollections tests that collections in one store are indexed, searched and dropped separately.
+func TestCollections(t *testing.T) {
+	db := storage.NewMemoryStore()
+	collections := indexer.NewCollections(db)
+
+	products, err := collections.Create(indexer.CollectionConfig{
+		Name:     "products",
+		Analyzer: analyzer.Config{StopWords: []string{"cheap"}},
+	})
+	assert.NoError(t, err)
+	_, err = collections.Create(indexer.CollectionConfig{Name: "blogs"})
+	assert.NoError(t, err)
+
+	_, err = collections.Create(indexer.CollectionConfig{Name: "blogs"})
+	assert.ErrorIs(t, err, indexer.ErrCollectionExists)
+	_, err = collections.Create(indexer.CollectionConfig{Name: indexer.DefaultCollection})
+	assert.Error(t, err)
+	_, err = collections.Create(indexer.CollectionConfig{Name: "Blogs/2023"})
+	assert.Error(t, err)
+
+	list, err := collections.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, "blogs", list[0].Name)
+
+	// Index the same URL into the default collection and both named ones
+	index := func(store storage.Store, config *indexer.CollectionConfig, body string) {
+		idx := indexer.NewIndexer(store, nil)
+		if config != nil {
+			idx.SetCollection(*config)
+		}
+		assert.NoError(t, idx.IndexDocuments([]indexer.Document{{URL: URL1, Body: body}}))
+	}
+	blogs, err := collections.Get("blogs")
+	assert.NoError(t, err)
+	index(db, nil, "phones")
+	index(collections.Store(products), &products, "cheap phones")
+	index(collections.Store(blogs), &blogs, "cheap tablets")
+
+	searchIn := func(store storage.Store, config indexer.CollectionConfig, query string) []string {
+		s := search.NewSearcher(store)
+		s.SetAnalyzer(analyzer.New(config.Analyzer))
+		results, err := s.Search(query, &search.SearchOptions{})
+		assert.NoError(t, err)
+		return results
+	}
+	assert.Equal(t, []string{URL1}, searchIn(collections.Store(products), products, "phones"))
+	assert.Empty(t, searchIn(collections.Store(products), products, "tablets"))
+	assert.Equal(t, []string{URL1}, searchIn(collections.Store(blogs), blogs, "cheap tablets"))
+
+	// Stop words of a collection are not indexed
+	stats, err := indexer.NewIndexer(collections.Store(products), nil).SegmentStats()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Postings)
+
+	// Dropping a collection leaves the others untouched
+	assert.NoError(t, collections.Drop("blogs"))
+	assert.ErrorIs(t, collections.Drop("blogs"), indexer.ErrCollectionNotFound)
+	_, err = collections.Get("blogs")
+	assert.ErrorIs(t, err, indexer.ErrCollectionNotFound)
+	assert.NoError(t, db.View(func(r storage.Reader) error {
+		return r.ScanPrefix([]byte("collections/blogs/"), func(k, _ []byte) error {
+			t.Errorf("key %s of dropped collection left behind", k)
+			return nil
+		})
+	}))
+	assert.Equal(t, []string{URL1}, searchIn(collections.Store(products), products, "phones"))
+
+	results, err := search.NewSearcher(db).Search("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL1}, results)
+}
@@ -0,0 +1,147 @@
+package main_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDistributedCrawl tests two workers sharing one Redis-backed frontier.
+func TestDistributedCrawl(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+
+	var mutex sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		hits[r.URL.Path]++
+		mutex.Unlock()
+		w.Write([]byte(`<html><body><a href="/">home</a><a href="/a">a</a><a href="/b">b</a><a href="/c">c</a></body></html>`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	config := crawler.RedisFrontierConfig{JobID: "test", HostDelay: 10 * time.Millisecond}
+
+	seed := crawler.NewRedisFrontier(redisClient, config)
+	added, err := seed.Push(ctx, server.URL+"/", 0)
+	assert.NoError(t, err)
+	assert.True(t, added)
+
+	// Run two workers against the same frontier
+	var wg sync.WaitGroup
+	workers := make([]*crawler.Crawler, 2)
+	for i := range workers {
+		workerConfig := config
+		workerConfig.WorkerID = "worker-" + string(rune('a'+i))
+		workers[i] = crawler.NewCrawler(2, 2)
+
+		wg.Add(1)
+		go func(c *crawler.Crawler, f *crawler.RedisFrontier) {
+			defer wg.Done()
+			assert.NoError(t, c.CrawlDistributed(ctx, f))
+		}(workers[i], crawler.NewRedisFrontier(redisClient, workerConfig))
+	}
+	wg.Wait()
+
+	var urls []string
+	for _, c := range workers {
+		for _, doc := range c.GetDocuments() {
+			urls = append(urls, doc.URL)
+		}
+	}
+	sort.Strings(urls)
+	assert.Equal(t, []string{server.URL + "/", server.URL + "/a", server.URL + "/b", server.URL + "/c"}, urls)
+	assert.Equal(t, map[string]int{"/": 1, "/a": 1, "/b": 1, "/c": 1}, hits)
+
+	idle, err := seed.Idle(ctx)
+	assert.NoError(t, err)
+	assert.True(t, idle)
+}
+
+// TestRedisFrontierLeaseRecovery tests that URLs leased by a dead worker are handed out again.
+func TestRedisFrontierLeaseRecovery(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	dead := crawler.NewRedisFrontier(redisClient, crawler.RedisFrontierConfig{JobID: "test", WorkerID: "dead", LeaseTTL: 20 * time.Millisecond})
+	alive := crawler.NewRedisFrontier(redisClient, crawler.RedisFrontierConfig{JobID: "test", WorkerID: "alive"})
+
+	_, err := dead.Push(ctx, URL1, 0)
+	assert.NoError(t, err)
+
+	// The same URL is never queued twice
+	added, err := alive.Push(ctx, URL1, 0)
+	assert.NoError(t, err)
+	assert.False(t, added)
+
+	lease, err := dead.Lease(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, URL1, lease.URL)
+
+	// Nothing is available while the lease is held
+	lease, err = alive.Lease(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, lease)
+
+	// Once the lease expires the URL is recovered
+	time.Sleep(30 * time.Millisecond)
+	lease, err = alive.Lease(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, URL1, lease.URL)
+	assert.NoError(t, alive.Complete(ctx, lease))
+
+	idle, err := alive.Idle(ctx)
+	assert.NoError(t, err)
+	assert.True(t, idle)
+}
+
+// TestRedisFrontierRerun tests that a job crawls its URLs again once the seen set expires or is reset.
+func TestRedisFrontierRerun(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
+
+	ctx := context.Background()
+	frontier := crawler.NewRedisFrontier(redisClient, crawler.RedisFrontierConfig{JobID: "test", SeenTTL: time.Hour})
+
+	// crawl pushes and completes a URL, and reports whether it was admitted
+	crawl := func(url string) bool {
+		added, err := frontier.Push(ctx, url, 0)
+		assert.NoError(t, err)
+		if added {
+			lease, err := frontier.Lease(ctx)
+			assert.NoError(t, err)
+			assert.NoError(t, frontier.Complete(ctx, lease))
+		}
+		return added
+	}
+
+	assert.True(t, crawl(URL1))
+	assert.False(t, crawl(URL1))
+
+	// The seen set expires an hour after the last new URL
+	mr.FastForward(30 * time.Minute)
+	assert.True(t, crawl(URL2))
+	mr.FastForward(45 * time.Minute)
+	assert.False(t, crawl(URL1))
+	mr.FastForward(30 * time.Minute)
+	assert.True(t, crawl(URL1))
+
+	assert.NoError(t, frontier.Reset(ctx))
+	assert.Empty(t, mr.Keys())
+	assert.True(t, crawl(URL1))
+}
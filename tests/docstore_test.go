@@ -0,0 +1,85 @@
+package main_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/pipeline"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDocStore tests document ID assignment and stored fields.
+func TestDocStore(t *testing.T) {
+	db, err := storage.OpenBolt(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	date := time.Date(2023, 8, 4, 0, 0, 0, 0, time.UTC)
+	store := indexer.NewDocStore(db, indexer.DocStoreConfig{
+		StoredFields:  []string{indexer.StoredURL, indexer.StoredTitle, indexer.StoredDate, indexer.StoredExcerpt},
+		ExcerptLength: 11,
+	})
+
+	ids, err := store.Put([]indexer.Document{
+		{URL: URL1, Title: "All in one", Description: "not stored", Body: "  Hello   phones world", Date: date},
+		{URL: URL2, Title: "Product"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, ids)
+
+	// Storing a known URL again keeps its ID
+	ids, err = store.Put([]indexer.Document{{URL: URL1, Title: "All in one (updated)"}, {URL: URL3}})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1, 3}, ids)
+
+	doc, err := store.Get(2)
+	assert.NoError(t, err)
+	assert.Equal(t, &indexer.StoredDocument{ID: 2, URL: URL2, Title: "Product"}, doc)
+
+	doc, err = store.GetByURL(URL1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), doc.ID)
+	assert.Equal(t, "All in one (updated)", doc.Title)
+
+	missing, err := store.Get(42)
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+
+	first := indexer.NewDocStore(db, indexer.DefaultDocStoreConfig())
+	_, err = first.Put([]indexer.Document{{URL: URL1, Title: "All in one", Description: "Shop", Body: "  Hello   phones world", Date: date}})
+	assert.NoError(t, err)
+	doc, err = first.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Shop", doc.Description)
+	assert.Equal(t, "Hello phones world", doc.Excerpt)
+	assert.True(t, date.Equal(doc.Date))
+}
+
+// TestSearchDocuments tests that search results carry their stored documents.
+func TestSearchDocuments(t *testing.T) {
+	db, err := storage.OpenBolt(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	page, err := crawler.ParseDocument(URL3, strings.NewReader(`<html><head><title>Phones</title>
+		<meta name="description" content="Cheap phones"></head><body>Phones category</body></html>`))
+	assert.NoError(t, err)
+
+	p := pipeline.NewPipeline(indexer.NewIndexer(db, nil))
+	assert.NoError(t, p.Add(page))
+	assert.NoError(t, p.Flush())
+
+	docs, err := search.NewSearcher(db).SearchDocuments("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, docs, 1)
+	assert.Equal(t, URL3, docs[0].URL)
+	assert.Equal(t, "Phones", docs[0].Title)
+	assert.Equal(t, "Cheap phones", docs[0].Description)
+	assert.Equal(t, "Phones category", docs[0].Excerpt)
+}
@@ -0,0 +1,52 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExtractionProfiles tests extracting custom fields with per-site CSS selector profiles.
+func TestExtractionProfiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body>
+			<a class="title" title="Nokia 123" href="#">Nokia...</a>
+			<h4 class="price">$24.99</h4>
+			<ul class="breadcrumb"><li>Phones</li><li>Touch</li></ul>
+			<p class="reviews">11 reviews</p>
+		</body></html>`))
+	}))
+	defer server.Close()
+
+	c := crawler.NewCrawler(0, 1)
+	err := c.SetExtractionProfiles([]crawler.ExtractionProfile{{
+		Hosts: []string{"127.0.0.1"},
+		Fields: map[string]crawler.FieldRule{
+			"name":     {Selector: ".title", Attribute: "title"},
+			"price":    {Selector: ".price", Type: crawler.ValueTypeNumber},
+			"category": {Selector: ".breadcrumb li", Multiple: true},
+			"reviews":  {Selector: ".reviews", Regex: `(\d+) reviews`, Type: crawler.ValueTypeNumber},
+			"missing":  {Selector: ".nothing"},
+		},
+	}})
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Crawl(server.URL, 0))
+
+	docs := c.GetDocuments()
+	assert.Len(t, docs, 1)
+	assert.Equal(t, map[string]interface{}{
+		"name":     "Nokia 123",
+		"price":    24.99,
+		"category": []interface{}{"Phones", "Touch"},
+		"reviews":  11.0,
+	}, docs[0].Fields)
+	assert.Nil(t, docs[0].DOM())
+
+	// Invalid profiles are rejected up front
+	err = c.SetExtractionProfiles([]crawler.ExtractionProfile{{Fields: map[string]crawler.FieldRule{"x": {Selector: "p", Regex: "("}}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package main_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseAtomFeed tests parsing the entries of an Atom feed.
+func TestParseAtomFeed(t *testing.T) {
+	atom := `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <id>urn:uuid:1</id>
+    <title>Release notes</title>
+    <link rel="alternate" href="https://example.com/releases/1"/>
+    <summary>&lt;p&gt;New &lt;b&gt;phones&lt;/b&gt;&lt;/p&gt;</summary>
+    <author><name>Jane</name></author>
+    <published>2023-08-04T10:00:00Z</published>
+  </entry>
+</feed>`
+
+	entries, err := crawler.ParseFeed(strings.NewReader(atom))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "urn:uuid:1", entries[0].ID)
+	assert.Equal(t, "https://example.com/releases/1", entries[0].Link)
+	assert.Equal(t, "New phones", entries[0].Summary)
+	assert.Equal(t, "Jane", entries[0].Author)
+	assert.True(t, time.Date(2023, 8, 4, 10, 0, 0, 0, time.UTC).Equal(entries[0].Published))
+}
+
+// TestPollFeeds tests polling an RSS feed with conditional GET.
+func TestPollFeeds(t *testing.T) {
+	feedRequests := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed.xml":
+			feedRequests++
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(`<rss version="2.0"><channel>
+				<item><title>Live</title><link>` + server.URL + `/live</link><description>Live summary</description>
+					<author>ann@example.com</author><pubDate>Fri, 04 Aug 2023 10:00:00 +0000</pubDate></item>
+				<item><title>Gone</title><link>` + server.URL + `/gone</link><description>Gone summary</description></item>
+			</channel></rss>`))
+		case "/live":
+			w.Write([]byte("<html><body>Live page</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := crawler.NewCrawler(1, 1)
+	c.AddFeed(server.URL + "/feed.xml")
+	assert.NoError(t, c.PollFeeds())
+	assert.NoError(t, c.PollFeeds())
+	assert.Equal(t, 2, feedRequests)
+
+	docs := make(map[string]*crawler.Document)
+	for _, doc := range c.GetDocuments() {
+		docs[doc.URL] = doc
+	}
+	assert.Len(t, docs, 2)
+
+	live := docs[server.URL+"/live"]
+	assert.Contains(t, live.Text, "Live page")
+	assert.Contains(t, live.Text, "Live summary")
+	assert.Equal(t, "ann@example.com", live.Metadata["author"])
+	assert.Equal(t, 2023, live.Date.Year())
+
+	// The unreachable entry is still indexed from its summary
+	assert.Equal(t, "Gone summary", docs[server.URL+"/gone"].Text)
+}
+
+// TestPollFeedsSeen tests that only the entries of the latest feed document are remembered.
+func TestPollFeedsSeen(t *testing.T) {
+	items := []string{"a", "b"}
+	fetched := make(map[string]int)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed.xml" {
+			fetched[r.URL.Path]++
+			w.Write([]byte("<html><body>Page</body></html>"))
+			return
+		}
+		feed := `<rss version="2.0"><channel>`
+		for _, item := range items {
+			feed += `<item><guid>` + item + `</guid><link>` + server.URL + `/` + item + `</link></item>`
+		}
+		w.Write([]byte(feed + `</channel></rss>`))
+	}))
+	defer server.Close()
+
+	c := crawler.NewCrawler(1, 1)
+	c.AddFeed(server.URL + "/feed.xml")
+	assert.NoError(t, c.PollFeeds())
+
+	// Entries still in the feed are not fetched again
+	items = []string{"b", "c"}
+	assert.NoError(t, c.PollFeeds())
+	assert.Equal(t, map[string]int{"/a": 1, "/b": 1, "/c": 1}, fetched)
+
+	// Entries that dropped out of the feed are forgotten
+	items = []string{"a", "c"}
+	assert.NoError(t, c.PollFeeds())
+	assert.Equal(t, map[string]int{"/a": 2, "/b": 1, "/c": 1}, fetched)
+}
+
+// TestRunFeedsErrors tests that feeds failing to poll are reported to the hooks and polled again.
+func TestRunFeedsErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	var failed []string
+	c := crawler.NewCrawler(1, 1)
+	c.AddHook(crawler.HookFuncs{OnErrorFunc: func(url string, err error) {
+		failed = append(failed, url)
+	}})
+	c.AddFeed(server.URL + "/feed.xml")
+
+	// The second round reports the feed again, then stops the loop
+	stop := errors.New("stop")
+	rounds := 0
+	err := c.RunFeeds(context.Background(), time.Millisecond, func() error {
+		if rounds++; rounds == 2 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, []string{server.URL + "/feed.xml", server.URL + "/feed.xml"}, failed)
+}
@@ -0,0 +1,58 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFileSystemCrawl tests crawling a local directory tree with globs and ignore files.
+func TestFileSystemCrawl(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":         "build/\n*.log\n!keep.log\n",
+		"README.md":          "# Project docs\nWelcome",
+		"guide/intro.html":   "<html><head><title>Intro</title></head><body>Hello</body></html>",
+		"guide/notes.txt":    "plain notes",
+		"guide/debug.log":    "ignored",
+		"guide/keep.log":     "kept",
+		"build/output.md":    "ignored",
+		"vendor/lib/lib.md":  "excluded",
+		"assets/logo.bin":    "\x00\x01\x02\x03",
+		"guide/.gitignore":   "notes.txt\n",
+		"guide/sub/deep.md":  "# Deep\n",
+		"guide/sub/skip.txt": "skipped by include",
+	}
+	for name, content := range files {
+		filePath := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filePath), 0755))
+		assert.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+	}
+	mtime := time.Date(2023, 8, 4, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, os.Chtimes(filepath.Join(root, "README.md"), mtime, mtime))
+
+	c := crawler.NewCrawler(1, 1)
+	c.SetFileFilters([]string{"*.md", "*.html", "*.log", "**/*.bin"}, []string{"vendor/**"})
+	assert.NoError(t, c.Crawl("file://"+root, 0))
+
+	docs := make(map[string]*crawler.Document)
+	var names []string
+	for _, doc := range c.GetDocuments() {
+		rel, err := filepath.Rel(root, doc.URL[len("file://"):])
+		assert.NoError(t, err)
+		docs[filepath.ToSlash(rel)] = doc
+		names = append(names, filepath.ToSlash(rel))
+	}
+	sort.Strings(names)
+
+	assert.Equal(t, []string{"README.md", "guide/intro.html", "guide/keep.log", "guide/sub/deep.md"}, names)
+	assert.Equal(t, "Project docs", docs["README.md"].Title)
+	assert.Equal(t, crawler.FileTypeMarkdown, docs["README.md"].Metadata["fileType"])
+	assert.True(t, mtime.Equal(docs["README.md"].Date))
+	assert.Equal(t, "Intro", docs["guide/intro.html"].Title)
+}
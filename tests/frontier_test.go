@@ -0,0 +1,50 @@
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCrawlerTrapDetection tests that infinite URL spaces are blocked and reported.
+func TestCrawlerTrapDetection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var link string
+		switch {
+		case r.URL.Path == "/":
+			link = `<a href="/calendar/1">calendar</a><a href="loop/">loop</a><a href="/#top">top</a>`
+		case strings.HasPrefix(r.URL.Path, "/calendar/"):
+			day, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/calendar/"))
+			link = fmt.Sprintf(`<a href="/calendar/%d">next</a>`, day+1)
+		default:
+			link = `<a href="loop/">deeper</a>`
+		}
+		w.Write([]byte("<html><body>" + link + "</body></html>"))
+	}))
+	defer server.Close()
+
+	c := crawler.NewCrawler(10, 1)
+	c.SetTrapConfig(crawler.TrapConfig{
+		MaxRepeatedSegments: 2,
+		MaxPatternCount:     2,
+	})
+	assert.NoError(t, c.Crawl(server.URL+"/", 0))
+	c.Wait()
+
+	report := c.Report()
+	assert.Equal(t, int64(5), report.Fetched)
+
+	kinds := make(map[string]crawler.Trap)
+	for _, trap := range report.Traps {
+		kinds[trap.Kind] = trap
+	}
+	assert.Len(t, kinds, 2)
+	assert.Equal(t, server.URL+"/calendar/3", kinds[crawler.TrapPathPattern].Example)
+	assert.Equal(t, server.URL+"/loop/loop/loop/", kinds[crawler.TrapRepeatingPath].Example)
+}
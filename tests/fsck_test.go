@@ -0,0 +1,116 @@
+package main_test
+
+import (
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/codec"
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFsck tests that the index checker finds damaged structures and repairs them from the document store.
+func TestFsck(t *testing.T) {
+	store := storage.NewMemoryStore()
+	idx := indexer.NewIndexer(store, nil)
+	assert.NoError(t, idx.IndexDocuments([]indexer.Document{
+		{URL: URL1, Body: "cheap phones"},
+		{URL: URL2, Body: "phones and tablets"},
+	}))
+
+	report, err := idx.Fsck(false)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Issues)
+	assert.Equal(t, 1, report.Segments)
+	assert.Equal(t, 2, report.Documents)
+
+	// Damage the index behind the indexer's back
+	var segment, phones []byte
+	err = store.View(func(r storage.Reader) error {
+		return r.ScanPrefix([]byte("segment/"), func(k, _ []byte) error {
+			segment = append([]byte{}, k[:len("segment/")+8]...)
+			if string(k[len(segment):]) == "phones" {
+				phones = append([]byte{}, k...)
+			}
+			return nil
+		})
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, phones)
+
+	dangling, err := codec.EncodePostings([]codec.Posting{{DocID: 99, Freq: 1}})
+	assert.NoError(t, err)
+	err = store.Update(func(w storage.Writer) error {
+		if err := w.Put(append(append([]byte{}, segment...), "cheap"...), []byte("corrupt")); err != nil {
+			return err
+		}
+		if err := w.Put(append(append([]byte{}, segment...), "ghost"...), dangling); err != nil {
+			return err
+		}
+		if err := w.Put(append([]byte("doc/length/"), indexer.EncodeDocID(2)...), []byte{7}); err != nil {
+			return err
+		}
+		if err := w.Put(append([]byte("doc/values/"), indexer.EncodeDocID(2)...), []byte("{")); err != nil {
+			return err
+		}
+		return w.Delete(append([]byte("doc/url/"), indexer.EncodeDocID(1)...))
+	})
+	assert.NoError(t, err)
+
+	report, err = idx.Fsck(false)
+	assert.NoError(t, err)
+	kinds := make(map[string]bool)
+	for _, issue := range report.Issues {
+		kinds[issue.Kind] = true
+		assert.False(t, issue.Repaired)
+	}
+	assert.True(t, kinds[indexer.IssueCorruptPostings])
+	assert.True(t, kinds[indexer.IssueDanglingPosting])
+	assert.True(t, kinds[indexer.IssueDocumentMapping])
+	assert.True(t, kinds[indexer.IssueDocumentLength])
+	assert.True(t, kinds[indexer.IssueDocumentValues])
+	assert.Equal(t, len(report.Issues), report.Unrepaired())
+
+	// Repairs restore the URL mapping from the stored document and the length from the
+	// postings, and drop what cannot be read, which is left unrepaired
+	report, err = idx.Fsck(true)
+	assert.NoError(t, err)
+	dropped := make(map[string]bool)
+	for _, issue := range report.Issues {
+		assert.NotEqual(t, issue.Repaired, issue.Dropped, issue.Detail)
+		if issue.Dropped {
+			dropped[issue.Kind] = true
+		}
+	}
+	assert.Equal(t, map[string]bool{
+		indexer.IssueCorruptPostings: true,
+		indexer.IssueDanglingPosting: true,
+		indexer.IssueDocumentValues:  true,
+	}, dropped)
+	assert.Equal(t, 3, report.Unrepaired())
+
+	report, err = idx.Fsck(false)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Issues)
+
+	results, err := search.NewSearcher(store).Search("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{URL1, URL2}, results)
+	results, err = search.NewSearcher(store).Search("ghost", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	// The document that lost its postings matches again once it is indexed again
+	results, err = search.NewSearcher(store).Search("cheap", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+	assert.NoError(t, idx.IndexDocuments([]indexer.Document{{URL: URL1, Body: "cheap phones"}}))
+	results, err = search.NewSearcher(store).Search("cheap", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL1}, results)
+
+	report, err = idx.Fsck(false)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Issues)
+}
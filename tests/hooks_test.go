@@ -0,0 +1,121 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/stretchr/testify/assert"
+)
+
+// tagHook tags every document it sees.
+type tagHook struct {
+	crawler.NopHook
+	tag string
+}
+
+func (h tagHook) AfterParse(doc *crawler.Document) error {
+	doc.Metadata["tags"] += h.tag
+	return nil
+}
+
+// TestCrawlerHooks tests that hooks can veto requests, mutate documents and observe errors in order.
+func TestCrawlerHooks(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			assert.Equal(t, "42", r.Header.Get("X-Trace"))
+			w.Write([]byte(`<html><body>Contact jane@example.com
+				<a href="/private">private</a><a href="/missing">missing</a></body></html>`))
+		case "/private":
+			t.Error("vetoed URL was fetched")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	email := regexp.MustCompile(`\S+@\S+`)
+	var errs []string
+
+	c := crawler.NewCrawler(1, 1)
+	c.AddHook(crawler.HookFuncs{
+		BeforeFetchFunc: func(req *http.Request) error {
+			if req.URL.Path == "/private" {
+				return crawler.ErrSkip
+			}
+			req.Header.Set("X-Trace", "42")
+			return nil
+		},
+		AfterParseFunc: func(doc *crawler.Document) error {
+			doc.Text = email.ReplaceAllString(doc.Text, "[email]")
+			return nil
+		},
+		OnErrorFunc: func(url string, err error) {
+			errs = append(errs, url)
+		},
+	})
+	c.AddHook(tagHook{tag: "a"})
+	c.AddHook(tagHook{tag: "b"})
+
+	assert.NoError(t, c.Crawl(server.URL+"/", 0))
+	c.Wait()
+
+	docs := c.GetDocuments()
+	assert.Len(t, docs, 1)
+	assert.True(t, strings.Contains(docs[0].Text, "Contact [email]"))
+	assert.Equal(t, "ab", docs[0].Metadata["tags"])
+	assert.Equal(t, []string{server.URL + "/missing"}, errs)
+}
+
+// TestCrawlerHooksFeedEntries tests that the after-parse hooks see feed entries merged into their pages.
+func TestCrawlerHooksFeedEntries(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed.xml":
+			w.Write([]byte(`<rss version="2.0"><channel>
+				<item><title>Live</title><link>` + server.URL + `/live</link><description>Mail ann@example.com</description>
+					<author>ann@example.com</author></item>
+				<item><title>Gone</title><link>` + server.URL + `/gone</link><description>Mail bob@example.com</description>
+					<author>bob@example.com</author></item>
+			</channel></rss>`))
+		case "/live":
+			w.Write([]byte("<html><body>Live page</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	email := regexp.MustCompile(`\S+@\S+`)
+
+	c := crawler.NewCrawler(1, 1)
+	c.AddHook(crawler.HookFuncs{
+		AfterParseFunc: func(doc *crawler.Document) error {
+			doc.Text = email.ReplaceAllString(doc.Text, "[email]")
+			doc.Metadata["author"] = email.ReplaceAllString(doc.Metadata["author"], "[email]")
+			return nil
+		},
+	})
+	c.AddFeed(server.URL + "/feed.xml")
+	assert.NoError(t, c.PollFeeds())
+
+	docs := make(map[string]*crawler.Document)
+	for _, doc := range c.GetDocuments() {
+		docs[doc.URL] = doc
+	}
+	assert.Len(t, docs, 2)
+
+	live := docs[server.URL+"/live"]
+	assert.Equal(t, "Mail [email]\nLive page", live.Text)
+	assert.Equal(t, "[email]", live.Metadata["author"])
+
+	gone := docs[server.URL+"/gone"]
+	assert.Equal(t, "Mail [email]", gone.Text)
+	assert.Equal(t, "[email]", gone.Metadata["author"])
+}
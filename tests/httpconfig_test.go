@@ -0,0 +1,112 @@
+package main_test
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCrawlerHTTPConfig tests per-job and per-host request settings.
+func TestCrawlerHTTPConfig(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte("<html><body>Staging</body></html>"))
+	}))
+	defer server.Close()
+
+	// Trust the test server through a custom CA bundle
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(caFile, caPEM, 0644))
+
+	t.Setenv("STAGING_PASSWORD", "secret")
+
+	c := crawler.NewCrawler(0, 1)
+	err := c.SetHTTPConfig(crawler.HTTPConfig{
+		HostHTTPConfig: crawler.HostHTTPConfig{
+			UserAgent: "test-agent",
+			Headers:   map[string]string{"X-Job": "docs"},
+		},
+		Hosts: map[string]crawler.HostHTTPConfig{
+			"127.0.0.1": {
+				Headers:   map[string]string{"X-Env": "staging"},
+				Cookies:   map[string]string{"session": "abc"},
+				BasicAuth: &crawler.BasicAuth{Username: "crawler", Password: "${STAGING_PASSWORD}"},
+				TLS:       crawler.TLSConfig{CAFile: caFile},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Crawl(server.URL, 0))
+	assert.Len(t, c.GetDocuments(), 1)
+
+	assert.Equal(t, "test-agent", got.Header.Get("User-Agent"))
+	assert.Equal(t, "docs", got.Header.Get("X-Job"))
+	assert.Equal(t, "staging", got.Header.Get("X-Env"))
+
+	username, password, ok := got.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "crawler", username)
+	assert.Equal(t, "secret", password)
+
+	cookie, err := got.Cookie("session")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", cookie.Value)
+}
+
+// TestCrawlerHTTPConfigOffSite tests that the job's credentials and cookies are not sent to other sites.
+func TestCrawlerHTTPConfigOffSite(t *testing.T) {
+	requests := make(map[string]*http.Request)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.Host] = r
+		w.Write([]byte("<html><body>Page</body></html>"))
+	}))
+	defer server.Close()
+
+	// The same server is another site when reached by another host name
+	site := server.URL
+	offSite := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	config := crawler.HTTPConfig{
+		HostHTTPConfig: crawler.HostHTTPConfig{
+			Headers:     map[string]string{"X-Api-Key": "key", "Accept": "text/html"},
+			Cookies:     map[string]string{"session": "abc"},
+			BearerToken: "token",
+		},
+	}
+	assert.True(t, config.HasSecrets())
+
+	c := crawler.NewCrawler(0, 1)
+	c.SetFilterDomain(site)
+	assert.NoError(t, c.SetHTTPConfig(config))
+
+	assert.NoError(t, c.Crawl(site, 0))
+	assert.NoError(t, c.Crawl(offSite, 0))
+	assert.Len(t, requests, 2)
+
+	got := requests[strings.TrimPrefix(site, "http://")]
+	assert.Equal(t, "Bearer token", got.Header.Get("Authorization"))
+	assert.Equal(t, "key", got.Header.Get("X-Api-Key"))
+	_, err := got.Cookie("session")
+	assert.NoError(t, err)
+
+	got = requests[strings.TrimPrefix(offSite, "http://")]
+	assert.Equal(t, "text/html", got.Header.Get("Accept"))
+	assert.Empty(t, got.Header.Get("X-Api-Key"))
+	assert.Empty(t, got.Header.Get("Authorization"))
+	assert.Empty(t, got.Cookies())
+
+	// Settings sent to every host hold no secrets
+	assert.False(t, crawler.HTTPConfig{HostHTTPConfig: crawler.HostHTTPConfig{
+		Headers: map[string]string{"Accept": "text/html"},
+	}}.HasSecrets())
+}
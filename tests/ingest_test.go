@@ -0,0 +1,61 @@
+package main_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/pipeline"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// warcResponse builds a WARC response record for the given URL and HTML body.
+func warcResponse(url, html string) string {
+	payload := "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n" + html
+	return fmt.Sprintf("WARC/1.0\r\nWARC-Type: response\r\nWARC-Target-URI: %s\r\nWARC-Date: 2023-08-04T10:00:00Z\r\n"+
+		"Content-Type: application/http; msgtype=response\r\nContent-Length: %d\r\n\r\n%s\r\n\r\n", url, len(payload), payload)
+}
+
+// TestOfflineIngestion tests indexing WARC records and HTML directories without network access.
+func TestOfflineIngestion(t *testing.T) {
+	dir := t.TempDir()
+
+	// Create a temporary BoltDB instance
+	db, err := storage.OpenBolt(filepath.Join(dir, "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	p := pipeline.NewPipeline(indexer.NewIndexer(db, nil))
+
+	// Ingest a WARC file with one page and one non-response record
+	warc := "WARC/1.0\r\nWARC-Type: warcinfo\r\nContent-Length: 4\r\n\r\ninfo\r\n\r\n" +
+		warcResponse(URL2, "<html><head><title>Nokia 123</title></head><body>Cheap phones</body></html>")
+	err = crawler.ReadWARC(strings.NewReader(warc), p.Add)
+	assert.NoError(t, err)
+
+	// Ingest a directory of HTML files
+	htmlDir := filepath.Join(dir, "site")
+	assert.NoError(t, os.MkdirAll(htmlDir, 0755))
+	err = os.WriteFile(filepath.Join(htmlDir, "phones.html"), []byte("<html><body>Phones category</body></html>"), 0644)
+	assert.NoError(t, err)
+	err = crawler.ReadHTMLDir(htmlDir, "https://example.com/docs", p.Add)
+	assert.NoError(t, err)
+
+	assert.NoError(t, p.Flush())
+
+	// Search the offline index
+	s := search.NewSearcher(db)
+	results, err := s.Search("nokia", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL2}, results)
+
+	results, err = s.Search("category", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/docs/phones.html"}, results)
+}
@@ -25,8 +25,7 @@ func TestSearchEngineIntegration(t *testing.T) {
 		err := idx.Index(data)
 		assert.NoError(t, err)
 
-		// Create a new searcher with the same in-memory BoltDB instance
-		// Note: You should pass db.DB to search.NewSearcher() as it expects *bolt.DB.
+		// Create a new searcher with the same in-memory store
 		s := search.NewSearcher(db.DB)
 
 		// Search for "test" keyword
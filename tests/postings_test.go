@@ -0,0 +1,162 @@
+package main_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"path/filepath"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/codec"
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPostingsEncoding tests the versioned delta + varint postings encoding.
+func TestPostingsEncoding(t *testing.T) {
+	postings := []codec.Posting{
+		{DocID: 3, Freq: 3, Positions: []uint32{0, 7, 300}},
+		{DocID: 1000, Freq: 2},
+	}
+
+	data, err := codec.EncodePostings(postings)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{'P', 'L', codec.PostingsVersion}, data[:3])
+	decoded, err := codec.DecodePostings(data)
+	assert.NoError(t, err)
+	assert.Equal(t, postings, decoded)
+
+	merged := codec.MergePostings(postings, []codec.Posting{{DocID: 5, Freq: 1}, {DocID: 3, Freq: 1, Positions: []uint32{2}}})
+	assert.Equal(t, []uint64{3, 5, 1000}, []uint64{merged[0].DocID, merged[1].DocID, merged[2].DocID})
+	assert.Equal(t, uint32(1), merged[0].Freq)
+
+	_, err = codec.DecodePostings(data[:len(data)-1])
+	assert.ErrorIs(t, err, codec.ErrCorruptPostings)
+	_, err = codec.DecodePostings([]byte("https://example.com"))
+	assert.ErrorIs(t, err, codec.ErrNotPostings)
+	_, err = codec.DecodePostings([]byte{'P', 'L', codec.PostingsVersion + 1, 0})
+	assert.ErrorIs(t, err, codec.ErrUnsupportedVersion)
+
+	_, err = codec.EncodePostings([]codec.Posting{{DocID: 2, Freq: 1}, {DocID: 1, Freq: 1}})
+	assert.Error(t, err)
+	_, err = codec.EncodePostings([]codec.Posting{{DocID: 1, Freq: 2, Positions: []uint32{4}}})
+	assert.Error(t, err)
+}
+
+// TestMigrate tests rewriting legacy IndexBucket values into postings lists.
+func TestMigrate(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "index.db"), 0666, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	// Write the formats of earlier versions
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte("IndexBucket"))
+		if err != nil {
+			return err
+		}
+		var compressed bytes.Buffer
+		gzw := gzip.NewWriter(&compressed)
+		if err := gob.NewEncoder(gzw).Encode([]string{URL3}); err != nil {
+			return err
+		}
+		gzw.Close()
+
+		bucket.Put([]byte("test"), []byte(URL2+","+URL3))
+		bucket.Put([]byte("phones"), compressed.Bytes())
+		bucket.Put([]byte(URL1), []byte("Cheap tablets, phones and laptops"))
+		bucket.Put([]byte("broken"), []byte("not a url"))
+		return nil
+	})
+	assert.NoError(t, err)
+
+	store, err := storage.NewBoltStore(db)
+	assert.NoError(t, err)
+	idx := indexer.NewIndexer(store, nil)
+	report, err := idx.Migrate()
+	assert.NoError(t, err)
+	assert.Equal(t, &indexer.MigrationReport{Terms: 2, Documents: 1, Skipped: []string{"broken"}}, report)
+
+	urls, err := idx.Query("test")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{URL2, URL3}, urls)
+
+	results, err := search.NewSearcher(store).Search("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{URL1, URL3}, results)
+
+	err = db.View(func(tx *bolt.Tx) error {
+		assert.Nil(t, tx.Bucket([]byte("IndexBucket")))
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// Migrating again is a no-op
+	report, err = idx.Migrate()
+	assert.NoError(t, err)
+	assert.Equal(t, &indexer.MigrationReport{}, report)
+}
+
+// TestIndexDocuments tests positional indexing and relevance by term frequency.
+func TestIndexDocuments(t *testing.T) {
+	db, err := storage.OpenBolt(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, nil)
+	err = idx.IndexDocuments([]indexer.Document{
+		{URL: URL1, Title: "Phones", Body: "cheap tablets"},
+		{URL: URL2, Title: "Phones", Body: "phones and more phones"},
+	})
+	assert.NoError(t, err)
+
+	err = db.View(func(r storage.Reader) error {
+		it := indexer.ReadPostings(r, "phones")
+		var got []codec.Posting
+		for it.Next() {
+			got = append(got, it.Posting())
+		}
+		assert.NoError(t, it.Err())
+		assert.Equal(t, []codec.Posting{
+			{DocID: 1, Freq: 1, Positions: []uint32{0}},
+			{DocID: 2, Freq: 3, Positions: []uint32{0, 1, 4}},
+		}, got)
+
+		length, ok, err := indexer.ReadDocLength(r, 2)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, 5, length)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	results, err := search.NewSearcher(db).Search("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL2, URL1}, results)
+
+	urls, err := idx.Query("tablets")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL1}, urls)
+}
+
+// TestLegacyFixtures tests that the index fixtures keep the baseline layout that Migrate has to handle.
+func TestLegacyFixtures(t *testing.T) {
+	for _, fixture := range []string{"data/mydb.db", "../data/mydb.db"} {
+		db, err := bolt.Open(fixture, 0444, &bolt.Options{ReadOnly: true})
+		assert.NoError(t, err, fixture)
+
+		var buckets []string
+		err = db.View(func(tx *bolt.Tx) error {
+			return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+				buckets = append(buckets, string(name))
+				return nil
+			})
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"IndexBucket"}, buckets, fixture)
+		assert.NoError(t, db.Close())
+	}
+}
@@ -0,0 +1,121 @@
+package main_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/codec"
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSplitRange tests that the trie prefixes of a range cover exactly its values.
+func TestSplitRange(t *testing.T) {
+	assert.Less(t, codec.SortableFloat64(-1.5), codec.SortableFloat64(0))
+	assert.Equal(t, codec.SortableFloat64(0), codec.SortableFloat64(-1*0.0))
+	assert.Less(t, codec.SortableFloat64(0), codec.SortableFloat64(0.1))
+	assert.Less(t, codec.SortableInt64(-1), codec.SortableInt64(1))
+
+	// covered returns the values in [from, to] matched by the prefixes of the range [lo, hi]
+	covered := func(lo, hi, from, to uint64) []uint64 {
+		var values []uint64
+		prefixes := make(map[uint64]bool)
+		codec.SplitRange(lo, hi, func(shift uint, prefix uint64) {
+			prefixes[uint64(shift)<<58|prefix] = true
+		})
+		for value := from; value <= to && value >= from; value++ {
+			for shift := uint(0); shift < 64; shift += codec.TriePrecisionStep {
+				if prefixes[uint64(shift)<<58|value>>shift] {
+					values = append(values, value)
+					break
+				}
+			}
+		}
+		return values
+	}
+
+	for _, r := range [][2]uint64{{0, 0}, {3, 17}, {16, 31}, {15, 256}, {1, 1000}, {300, 4095}} {
+		var want []uint64
+		for value := r[0]; value <= r[1]; value++ {
+			want = append(want, value)
+		}
+		assert.Equal(t, want, covered(r[0], r[1], 0, 5000), r)
+	}
+	assert.Empty(t, covered(5, 4, 0, 100))
+
+	max := ^uint64(0)
+	assert.Equal(t, []uint64{max - 2, max - 1, max}, covered(max-2, max, max-100, max))
+
+	count := 0
+	codec.SplitRange(0, max, func(uint, uint64) { count++ })
+	assert.Equal(t, 16, count)
+}
+
+// TestRangeSearch tests range filters on numeric and date fields, in the query and in the options.
+func TestRangeSearch(t *testing.T) {
+	schema := indexer.Schema{Fields: []indexer.FieldConfig{
+		{Name: "title", Type: indexer.TypeText, Indexed: true, Stored: true},
+		{Name: "price", Type: indexer.TypeNumeric, Indexed: true, Stored: true, Sortable: true},
+		{Name: "published", Type: indexer.TypeDate, Indexed: true, Sortable: true},
+		{Name: indexer.FieldDate, Type: indexer.TypeDate, Sortable: true},
+	}}
+	db := storage.NewMemoryStore()
+	idx := indexer.NewIndexer(db, nil)
+	idx.SetSchema(schema)
+	assert.NoError(t, idx.IndexDocuments([]indexer.Document{
+		{URL: URL1, Title: "Phone case", Date: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC),
+			Fields: map[string]interface{}{"price": -5.5, "published": "2022-12-31T23:59:59Z"}},
+		{URL: URL2, Title: "Nokia phone", Date: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+			Fields: map[string]interface{}{"price": 99.99, "published": "2023-12-31T18:00:00Z"}},
+		{URL: URL3, Title: "Smart phone",
+			Fields: map[string]interface{}{"price": 100, "published": "2024-01-01"}},
+	}))
+
+	s := search.NewSearcher(db)
+	s.SetSchema(schema)
+	searchFor := func(query string, filters map[string]string) []string {
+		results, err := s.Search(query, &search.SearchOptions{Filters: filters, SortBy: "price"})
+		assert.NoError(t, err, query)
+		return results
+	}
+
+	assert.Equal(t, []string{URL1, URL2}, searchFor("phone price:<100", nil))
+	assert.Equal(t, []string{URL1, URL2, URL3}, searchFor("price:<=100", nil))
+	assert.Equal(t, []string{URL2, URL3}, searchFor("price:>-5.5", nil))
+	assert.Equal(t, []string{URL1}, searchFor("", map[string]string{"price": "[-10 TO 0]"}))
+	assert.Equal(t, []string{URL2}, searchFor("", map[string]string{"price": "{-5.5 TO 100}"}))
+	assert.Equal(t, []string{URL3}, searchFor("smart price:[100 TO *]", nil))
+	assert.Equal(t, []string{URL2}, searchFor(`published:"[2023-01-01 TO 2023-12-31]"`, nil))
+	assert.Equal(t, []string{URL2}, searchFor("phone published:[2023-01-01 TO 2023-12-31]", nil))
+	assert.Equal(t, []string{URL1, URL2}, searchFor("published:{* TO 2024-01-01}", nil))
+	assert.Equal(t, []string{URL2, URL3}, searchFor("published:>2022-12-31", nil))
+	assert.Equal(t, []string{URL1}, searchFor("published:2022-12-31", nil))
+	assert.Equal(t, []string{URL2}, searchFor("published:[2023-01-01 TO 2023-12-31] price:>50", nil))
+	assert.Empty(t, searchFor("published:[2023-01-01 TO 2023-12-31] price:>100", nil))
+
+	_, err := s.Search("price:[1 TO cheap]", &search.SearchOptions{})
+	assert.Error(t, err)
+	_, err = s.Search("", &search.SearchOptions{Filters: map[string]string{"date": "<2023-01-01"}})
+	assert.Error(t, err)
+
+	// Sorting by date uses the date field of the schema, and documents without one come last
+	results, err := s.Search("phone", &search.SearchOptions{SortBy: search.SortByDate})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL2, URL1, URL3}, results)
+}
+
+// TestSortByDate tests that results are sorted by the dates of their documents, newest first.
+func TestSortByDate(t *testing.T) {
+	db := storage.NewMemoryStore()
+	assert.NoError(t, indexer.NewIndexer(db, nil).IndexDocuments([]indexer.Document{
+		{URL: URL1, Body: "phone", Date: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{URL: URL2, Body: "phone"},
+		{URL: URL3, Body: "phone phone", Date: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}))
+
+	results, err := search.NewSearcher(db).Search("phone", &search.SearchOptions{SortBy: search.SortByDate})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL3, URL1, URL2}, results)
+}
@@ -0,0 +1,161 @@
+package main_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/pipeline"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// productSchema is a schema of product pages with fields of every type.
+var productSchema = indexer.Schema{Fields: []indexer.FieldConfig{
+	{Name: "title", Type: indexer.TypeText, Indexed: true, Stored: true, Boost: 3},
+	{Name: "body", Type: indexer.TypeText, Indexed: true},
+	{Name: "category", Type: indexer.TypeKeyword, Indexed: true, Stored: true, Facetable: true},
+	{Name: "price", Type: indexer.TypeNumeric, Indexed: true, Stored: true, Sortable: true, Required: true},
+	{Name: "published", Type: indexer.TypeDate, Sortable: true},
+	{Name: "inStock", Type: indexer.TypeBoolean, Indexed: true},
+}}
+
+// TestSchemaValidate tests that inconsistent schemas are rejected.
+func TestSchemaValidate(t *testing.T) {
+	assert.NoError(t, productSchema.Validate())
+	assert.NoError(t, indexer.Schema{}.Validate())
+
+	invalid := []indexer.FieldConfig{
+		{Name: "title", Type: indexer.TypeText, Sortable: true},
+		{Name: "price", Type: "money"},
+		{Name: "price", Type: indexer.TypeNumeric, Boost: 2},
+		{Name: "title", Type: indexer.TypeNumeric},
+		{Name: "a:b", Type: indexer.TypeKeyword},
+	}
+	for _, field := range invalid {
+		assert.Error(t, indexer.Schema{Fields: []indexer.FieldConfig{field}}.Validate(), field.Name)
+	}
+	assert.Error(t, indexer.Schema{Fields: []indexer.FieldConfig{
+		{Name: "price", Type: indexer.TypeNumeric},
+		{Name: "price", Type: indexer.TypeKeyword},
+	}}.Validate())
+}
+
+// TestSchemaSearch tests that typed fields are validated at ingest and searched, filtered, sorted and faceted.
+func TestSchemaSearch(t *testing.T) {
+	db := storage.NewMemoryStore()
+	collections := indexer.NewCollections(db)
+	_, err := collections.Create(indexer.CollectionConfig{Name: "products", Schema: indexer.Schema{Fields: []indexer.FieldConfig{
+		{Name: "price", Type: indexer.TypeNumeric, Sortable: true},
+		{Name: "price", Type: indexer.TypeNumeric},
+	}}})
+	assert.Error(t, err)
+	config, err := collections.Create(indexer.CollectionConfig{Name: "products", Schema: productSchema})
+	assert.NoError(t, err)
+
+	store := collections.Store(config)
+	idx := indexer.NewIndexer(store, nil)
+	idx.SetCollection(config)
+	writer, err := indexer.NewWriter(idx, indexer.WriterConfig{})
+	assert.NoError(t, err)
+
+	// Documents not matching the schema are rejected
+	assert.ErrorIs(t, writer.Add(indexer.Document{URL: URL1, Fields: map[string]interface{}{"price": "cheap"}}), indexer.ErrInvalidDocument)
+	assert.ErrorIs(t, writer.Add(indexer.Document{URL: URL1, Title: "phone"}), indexer.ErrInvalidDocument)
+	assert.Zero(t, writer.Pending())
+
+	assert.NoError(t, writer.Add(
+		indexer.Document{URL: URL1, Title: "Phone case", Body: "A case for phones",
+			Fields: map[string]interface{}{"category": "accessories", "price": "9.5", "published": "2023-05-01", "inStock": true}},
+		indexer.Document{URL: URL2, Title: "Nokia", Body: "A sturdy phone",
+			Fields: map[string]interface{}{"category": "phones", "price": 120.0, "published": time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), "inStock": "false"}},
+		indexer.Document{URL: URL3, Title: "Phone", Body: "A smart phone",
+			Fields: map[string]interface{}{"category": "phones", "price": 499, "published": "2023-09-01T12:00:00Z", "inStock": true}},
+	))
+	assert.NoError(t, writer.Commit())
+
+	s := search.NewSearcher(store)
+	s.SetCollection(config)
+
+	// Matches in the boosted title outweigh matches in the body
+	results, err := s.Search("phone", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL3, URL1, URL2}, results)
+
+	results, err = s.Search("phone", &search.SearchOptions{Filters: map[string]string{"category": "phones", "inStock": "true"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL3}, results)
+
+	// Filters alone select documents
+	results, err = s.Search("", &search.SearchOptions{Filters: map[string]string{"price": "120"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL2}, results)
+	_, err = s.Search("", &search.SearchOptions{Filters: map[string]string{"published": "2023-01-01"}})
+	assert.Error(t, err)
+
+	results, err = s.Search("phone", &search.SearchOptions{SortBy: "-price"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL3, URL2, URL1}, results)
+	results, err = s.Search("phone", &search.SearchOptions{SortBy: "published"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL2, URL1, URL3}, results)
+	_, err = s.Search("phone", &search.SearchOptions{SortBy: "title"})
+	assert.Error(t, err)
+
+	facets, err := s.Facets(results, "category")
+	assert.NoError(t, err)
+	assert.Equal(t, []search.FacetCount{{Value: "phones", Count: 2}, {Value: "accessories", Count: 1}}, facets["category"])
+	_, err = s.Facets(results, "price")
+	assert.Error(t, err)
+
+	// Only stored fields are kept for display
+	doc, err := idx.Documents().GetByURL(URL1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Phone case", doc.Title)
+	assert.Empty(t, doc.Excerpt)
+	assert.Equal(t, map[string]interface{}{"category": "accessories", "price": 9.5}, doc.Fields)
+}
+
+// TestSchemaStructuredData tests indexing crawled pages carrying schema.org data into a typed collection.
+func TestSchemaStructuredData(t *testing.T) {
+	doc, err := crawler.ParseDocument("https://example.com/nokia", strings.NewReader(jsonLDPage))
+	assert.NoError(t, err)
+	assert.Contains(t, doc.Fields, "currency")
+
+	schema := indexer.Schema{Fields: []indexer.FieldConfig{
+		{Name: "body", Type: indexer.TypeText, Indexed: true},
+		{Name: "price", Type: indexer.TypeNumeric, Indexed: true, Stored: true},
+	}}
+	db := storage.NewMemoryStore()
+	collections := indexer.NewCollections(db)
+	config, err := collections.Create(indexer.CollectionConfig{Name: "products", Schema: schema})
+	assert.NoError(t, err)
+
+	// Fields the schema does not declare are dropped
+	idx := indexer.NewIndexer(collections.Store(config), nil)
+	idx.SetCollection(config)
+	p := pipeline.NewPipeline(idx)
+	assert.NoError(t, p.Add(doc))
+	assert.NoError(t, p.Flush())
+
+	s := search.NewSearcher(collections.Store(config))
+	s.SetCollection(config)
+	results, err := s.Search("nokia price:<30", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{doc.URL}, results)
+
+	stored, err := idx.Documents().GetByURL(doc.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"price": 24.99}, stored.Fields)
+
+	// A strict schema rejects them
+	schema.Strict = true
+	strict, err := collections.Create(indexer.CollectionConfig{Name: "strict", Schema: schema})
+	assert.NoError(t, err)
+	idx = indexer.NewIndexer(collections.Store(strict), nil)
+	idx.SetCollection(strict)
+	assert.ErrorIs(t, pipeline.NewPipeline(idx).Add(doc), indexer.ErrInvalidDocument)
+}
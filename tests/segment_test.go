@@ -0,0 +1,69 @@
+package main_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/codec"
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSegments tests that batches become segments, searches merge them and merging compacts them.
+func TestSegments(t *testing.T) {
+	db, err := storage.OpenBolt(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, nil)
+	idx.SetMergePolicy(indexer.MergePolicy{SegmentsPerTier: 3, MinSegmentBytes: 1 << 20})
+
+	// Every batch is written as a segment of its own
+	assert.NoError(t, idx.IndexDocuments([]indexer.Document{{URL: URL1, Body: "phones"}}))
+	assert.NoError(t, idx.IndexDocuments([]indexer.Document{{URL: URL2, Body: "phones phones"}}))
+	assert.NoError(t, idx.IndexDocuments([]indexer.Document{{URL: URL1, Body: "phones phones phones"}}))
+	_, err = idx.DeleteDocument(URL2)
+	assert.NoError(t, err)
+
+	stats, err := idx.SegmentStats()
+	assert.NoError(t, err)
+	assert.Len(t, stats.Segments, 3)
+	assert.Equal(t, 3, stats.Postings)
+
+	// The newest segment holding a document wins
+	readPhones := func() []codec.Posting {
+		var postings []codec.Posting
+		err := db.View(func(r storage.Reader) error {
+			it := indexer.ReadPostings(r, "phones")
+			for it.Next() {
+				postings = append(postings, it.Posting())
+			}
+			return it.Err()
+		})
+		assert.NoError(t, err)
+		return postings
+	}
+	assert.Equal(t, []codec.Posting{{DocID: 1, Freq: 3, Positions: []uint32{0, 1, 2}}}, readPhones())
+
+	// Three small segments share a tier and are merged, without the deleted document
+	merges, err := idx.Merge()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, merges)
+
+	stats, err = idx.SegmentStats()
+	assert.NoError(t, err)
+	assert.Len(t, stats.Segments, 1)
+	assert.Equal(t, uint64(3), stats.Segments[0].ID)
+	assert.Equal(t, 1, stats.Postings)
+	assert.Equal(t, []codec.Posting{{DocID: 1, Freq: 3, Positions: []uint32{0, 1, 2}}}, readPhones())
+
+	merges, err = idx.Merge()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, merges)
+
+	results, err := search.NewSearcher(db).Search("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL1}, results)
+}
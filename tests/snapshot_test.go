@@ -0,0 +1,86 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSnapshots tests full and incremental snapshots and restoring them into another engine.
+func TestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	db, err := storage.OpenBolt(filepath.Join(dir, "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, nil)
+	assert.NoError(t, idx.IndexDocuments([]indexer.Document{
+		{URL: URL1, Body: "cheap phones"},
+		{URL: URL2, Body: "phones and tablets"},
+	}))
+
+	full := filepath.Join(dir, "full")
+	manifest, err := idx.Snapshot(full, "")
+	assert.NoError(t, err)
+	assert.Empty(t, manifest.Base)
+	assert.NotEmpty(t, manifest.Chunks)
+
+	// A snapshot of an unchanged index only refers to the chunks of its base
+	unchanged := filepath.Join(dir, "unchanged")
+	manifest, err = idx.Snapshot(unchanged, full)
+	assert.NoError(t, err)
+	assert.Equal(t, "../full", manifest.Base)
+	for _, chunk := range manifest.Chunks {
+		assert.True(t, chunk.Base)
+	}
+	files, err := os.ReadDir(unchanged)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	// Changes after a snapshot are not part of it
+	_, err = idx.DeleteDocument(URL1)
+	assert.NoError(t, err)
+	incremental := filepath.Join(dir, "incremental")
+	_, err = idx.Snapshot(incremental, unchanged)
+	assert.NoError(t, err)
+
+	restore := func(snapshot string) []string {
+		store := storage.NewMemoryStore()
+		restored := indexer.NewIndexer(store, nil)
+		_, err := restored.Restore(snapshot)
+		assert.NoError(t, err)
+
+		results, err := search.NewSearcher(store).Search("phones", &search.SearchOptions{})
+		assert.NoError(t, err)
+		return results
+	}
+	assert.ElementsMatch(t, []string{URL1, URL2}, restore(unchanged))
+	assert.Equal(t, []string{URL2}, restore(incremental))
+
+	// Restoring into a LevelDB index works the same
+	level, err := storage.OpenLevelDB(filepath.Join(dir, "leveldb"))
+	assert.NoError(t, err)
+	defer level.Close()
+	_, err = indexer.NewIndexer(level, nil).Restore(incremental)
+	assert.NoError(t, err)
+	results, err := search.NewSearcher(level).Search("tablets", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL2}, results)
+
+	// Only empty indexes can be restored into
+	_, err = idx.Restore(full)
+	assert.Error(t, err)
+
+	// Corrupt chunks are detected before anything is written
+	manifest, err = storage.ReadManifest(full)
+	assert.NoError(t, err)
+	chunk := filepath.Join(full, manifest.Chunks[0].SHA256)
+	assert.NoError(t, os.WriteFile(chunk, []byte("corrupt"), 0644))
+	_, err = storage.VerifySnapshot(unchanged)
+	assert.ErrorIs(t, err, storage.ErrChecksumMismatch)
+}
@@ -0,0 +1,55 @@
+package main_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCrawlerStats tests the crawl counters and the JSON crawl report.
+func TestCrawlerStats(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<html><body><a href="` + server.URL + `/page">page</a><a href="` + server.URL + `/missing">missing</a></body></html>`))
+		case "/page":
+			w.Write([]byte("<html><body>Page</body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	c := crawler.NewCrawler(1, 1)
+	assert.NoError(t, c.Crawl(server.URL+"/", 0))
+	c.Wait()
+
+	stats := c.Stats()
+	assert.Equal(t, int64(0), stats.Queued)
+	assert.Equal(t, int64(0), stats.InFlight)
+	assert.Equal(t, int64(2), stats.Fetched)
+	assert.Equal(t, int64(1), stats.Failed)
+	assert.Equal(t, int64(1), stats.FailuresByStatus[http.StatusNotFound])
+	assert.Equal(t, int64(1), stats.FailuresByClass[crawler.ErrorClassStatus])
+	assert.Equal(t, int64(3), stats.Latency.Count)
+	assert.Equal(t, int64(2), stats.Hosts["127.0.0.1"].Fetched)
+	assert.Greater(t, stats.Bytes, int64(0))
+
+	// Write and read back the crawl report
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	assert.NoError(t, c.WriteReport(reportPath))
+
+	data, err := os.ReadFile(reportPath)
+	assert.NoError(t, err)
+
+	var report crawler.Stats
+	assert.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, stats.Fetched, report.Fetched)
+}
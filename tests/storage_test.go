@@ -0,0 +1,272 @@
+package main_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStores tests that every store implementation behaves the same.
+func TestStores(t *testing.T) {
+	boltStore, err := storage.OpenBolt(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer boltStore.Close()
+
+	levelStore, err := storage.OpenLevelDB(filepath.Join(t.TempDir(), "leveldb"))
+	assert.NoError(t, err)
+	defer levelStore.Close()
+
+	// A namespace must not see the keys around it
+	outer := storage.NewMemoryStore()
+	assert.NoError(t, outer.Update(func(w storage.Writer) error {
+		if err := w.Put([]byte("nr"), []byte("before")); err != nil {
+			return err
+		}
+		return w.Put([]byte("nt"), []byte("after"))
+	}))
+
+	stores := map[string]storage.Store{
+		"bolt":      boltStore,
+		"leveldb":   levelStore,
+		"memory":    storage.NewMemoryStore(),
+		"namespace": storage.Namespace(outer, "ns/"),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, store)
+		})
+	}
+}
+
+// testStore tests the reads, writes, batches and snapshots of a store.
+func testStore(t *testing.T, store storage.Store) {
+	scan := func(r storage.Reader, prefix string) []string {
+		var keys []string
+		err := r.ScanPrefix([]byte(prefix), func(key, value []byte) error {
+			keys = append(keys, string(key)+"="+string(value))
+			return nil
+		})
+		assert.NoError(t, err)
+		return keys
+	}
+
+	err := store.Update(func(w storage.Writer) error {
+		for _, key := range []string{"a/2", "a/1", "b/1", "a/3", "a\xff"} {
+			if err := w.Put([]byte(key), []byte(key[len(key)-1:])); err != nil {
+				return err
+			}
+		}
+
+		// A batch reads its own writes
+		assert.NoError(t, w.Delete([]byte("a/3")))
+		assert.Equal(t, []string{"a/1=1", "a/2=2"}, scan(w, "a/"))
+		value, err := w.Get([]byte("b/1"))
+		assert.Equal(t, []byte("1"), value)
+		return err
+	})
+	assert.NoError(t, err)
+
+	// A failed batch changes nothing
+	failed := errors.New("failed")
+	err = store.Update(func(w storage.Writer) error {
+		assert.NoError(t, w.Put([]byte("a/4"), []byte("4")))
+		assert.NoError(t, w.Delete([]byte("a/1")))
+		return failed
+	})
+	assert.ErrorIs(t, err, failed)
+
+	snapshot, err := store.Snapshot()
+	assert.NoError(t, err)
+	assert.NoError(t, store.Update(func(w storage.Writer) error {
+		return w.Delete([]byte("a/1"))
+	}))
+
+	err = store.View(func(r storage.Reader) error {
+		value, err := r.Get([]byte("a/1"))
+		assert.Nil(t, value)
+		assert.Equal(t, []string{"a/2=2"}, scan(r, "a/"))
+
+		var keys []string
+		err = r.Scan([]byte("a/2"), []byte("b/1"), func(key, _ []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+		assert.Equal(t, []string{"a/2", "a\xff"}, keys)
+		return err
+	})
+	assert.NoError(t, err)
+
+	// The snapshot still sees the deleted key
+	assert.Equal(t, []string{"a/1=1", "a/2=2"}, scan(snapshot, "a/"))
+	assert.NoError(t, snapshot.Release())
+
+	// An empty value is a key that exists
+	assert.NoError(t, store.Update(func(w storage.Writer) error {
+		return w.Put([]byte("e/1"), nil)
+	}))
+	err = store.View(func(r storage.Reader) error {
+		value, err := r.Get([]byte("e/1"))
+		assert.NotNil(t, value)
+		assert.Empty(t, value)
+		assert.Equal(t, []string{"e/1="}, scan(r, "e/"))
+		return err
+	})
+	assert.NoError(t, err)
+}
+
+// TestStoreUpdates tests that updated and deleted documents stop matching on every storage engine.
+func TestStoreUpdates(t *testing.T) {
+	for _, engine := range []string{storage.EngineBolt, storage.EngineLevelDB, storage.EngineMemory} {
+		t.Run(engine, func(t *testing.T) {
+			store, err := storage.Open(engine, filepath.Join(t.TempDir(), "index"))
+			assert.NoError(t, err)
+			defer store.Close()
+
+			idx := indexer.NewIndexer(store, nil)
+			assert.NoError(t, idx.IndexDocuments([]indexer.Document{
+				{URL: URL1, Body: "cheap phones"},
+				{URL: URL2, Body: "phones and tablets"},
+			}))
+			_, err = idx.UpdateDocument(indexer.Document{URL: URL1, Body: "cheap tablets"})
+			assert.NoError(t, err)
+			_, err = idx.DeleteDocument(URL2)
+			assert.NoError(t, err)
+
+			s := search.NewSearcher(store)
+			for query, want := range map[string][]string{"phones": nil, "tablets": {URL1}} {
+				results, err := s.Search(query, &search.SearchOptions{})
+				assert.NoError(t, err)
+				assert.Equal(t, want, results, query)
+			}
+
+			// Merging drops the dead postings and fsck finds nothing to repair
+			assert.NoError(t, idx.ForceMerge())
+			report, err := idx.Fsck(false)
+			assert.NoError(t, err)
+			assert.Empty(t, report.Issues)
+		})
+	}
+}
+
+// TestMemoryStoreIndex tests indexing and searching an index held in memory.
+func TestMemoryStoreIndex(t *testing.T) {
+	db, cleanup := indexer.NewInMemoryBoltDB()
+	defer cleanup()
+
+	idx := indexer.NewIndexer(db, nil)
+	assert.NoError(t, idx.IndexDocuments([]indexer.Document{
+		{URL: URL1, Body: "cheap phones"},
+		{URL: URL2, Body: "phones and more phones"},
+	}))
+	_, err := idx.DeleteDocument(URL1)
+	assert.NoError(t, err)
+
+	results, err := search.NewSearcher(db).Search("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL2}, results)
+}
+
+// TestMigrateFixtures tests migrating the index files written before the storage abstraction.
+func TestMigrateFixtures(t *testing.T) {
+	fixtures := map[string]*indexer.MigrationReport{
+		"data/mydb.db":    {Terms: 1},
+		"../data/mydb.db": {Documents: 1},
+	}
+	for fixture, want := range fixtures {
+		t.Run(fixture, func(t *testing.T) {
+			// Migrate a copy, so the fixture keeps the legacy layout
+			data, err := os.ReadFile(fixture)
+			assert.NoError(t, err)
+			path := filepath.Join(t.TempDir(), "index.db")
+			assert.NoError(t, os.WriteFile(path, data, 0666))
+
+			store, err := storage.OpenBolt(path)
+			assert.NoError(t, err)
+			defer store.Close()
+
+			idx := indexer.NewIndexer(store, nil)
+			report, err := idx.Migrate()
+			assert.NoError(t, err)
+			assert.Equal(t, want, report)
+
+			// The migrated index is consistent
+			fsck, err := idx.Fsck(false)
+			assert.NoError(t, err)
+			assert.Empty(t, fsck.Issues)
+
+			// New documents continue after the migrated ones
+			assert.NoError(t, idx.IndexDocuments([]indexer.Document{{URL: URL2, Body: "phones"}}))
+			results, err := search.NewSearcher(store).Search("phones", &search.SearchOptions{})
+			assert.NoError(t, err)
+			assert.Contains(t, results, URL2)
+
+			// Migrating again is a no-op
+			report, err = idx.Migrate()
+			assert.NoError(t, err)
+			assert.Equal(t, &indexer.MigrationReport{}, report)
+		})
+	}
+}
+
+// BenchmarkStores compares the ingest and query throughput of the storage engines.
+func BenchmarkStores(b *testing.B) {
+	docs := func(start, n int) []indexer.Document {
+		batch := make([]indexer.Document, n)
+		for i := range batch {
+			batch[i] = indexer.Document{
+				URL:  fmt.Sprintf("https://example.com/page/%d", start+i),
+				Body: fmt.Sprintf("cheap phones tablets and laptops number %d in stock", start+i),
+			}
+		}
+		return batch
+	}
+
+	for _, engine := range []string{storage.EngineBolt, storage.EngineLevelDB, storage.EngineMemory} {
+		b.Run(engine+"/ingest", func(b *testing.B) {
+			store, err := storage.Open(engine, filepath.Join(b.TempDir(), "index"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer store.Close()
+
+			// Every operation commits a batch of 100 documents
+			idx := indexer.NewIndexer(store, nil)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := idx.IndexDocuments(docs(i*100, 100)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(engine+"/query", func(b *testing.B) {
+			store, err := storage.Open(engine, filepath.Join(b.TempDir(), "index"))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer store.Close()
+
+			idx := indexer.NewIndexer(store, nil)
+			for i := 0; i < 10; i++ {
+				if err := idx.IndexDocuments(docs(i*100, 100)); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			searcher := search.NewSearcher(store)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := searcher.Search("cheap phones", &search.SearchOptions{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package main_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/pipeline"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	jsonLDPage = `<html><head><script type="application/ld+json">
+		{"@context": "https://schema.org", "@graph": [{"@type": "Product", "name": "Nokia 123",
+		 "offers": {"@type": "Offer", "price": "24.99", "priceCurrency": "USD", "availability": "https://schema.org/InStock"},
+		 "aggregateRating": {"ratingValue": 4.5, "reviewCount": "12"}, "releaseDate": "2023-08-04"}]}
+		</script></head><body>Nokia phone</body></html>`
+
+	microdataPage = `<html><body><div itemscope itemtype="https://schema.org/Product">
+		<span itemprop="name">Galaxy</span>
+		<div itemprop="brand" itemscope itemtype="https://schema.org/Brand"><span itemprop="name">Samsung</span></div>
+		<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+			<span itemprop="price" content="899.00">$899</span><meta itemprop="priceCurrency" content="USD">
+			<link itemprop="availability" href="https://schema.org/OutOfStock">
+		</div></div>Galaxy phone</body></html>`
+
+	rdfaPage = `<html><body><div vocab="https://schema.org/" typeof="Product">
+		<span property="name">iPhone</span>
+		<div property="offers" typeof="Offer"><span property="price">$1,099.00</span>
+		<span property="priceCurrency">USD</span><link property="availability" href="https://schema.org/InStock"></div>
+		</div>iPhone phone</body></html>`
+)
+
+// TestStructuredDataExtraction tests extracting schema.org Product data and searching on the typed fields.
+func TestStructuredDataExtraction(t *testing.T) {
+	jsonLD, err := crawler.ParseDocument("https://example.com/nokia", strings.NewReader(jsonLDPage))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"type":          "Product",
+		"name":          "Nokia 123",
+		"price":         24.99,
+		"currency":      "USD",
+		"availability":  "InStock",
+		"rating":        4.5,
+		"ratingCount":   12,
+		"datePublished": time.Date(2023, 8, 4, 0, 0, 0, 0, time.UTC),
+	}, jsonLD.Fields)
+
+	microdata, err := crawler.ParseDocument("https://example.com/galaxy", strings.NewReader(microdataPage))
+	assert.NoError(t, err)
+	assert.Equal(t, "Galaxy", microdata.Fields["name"])
+	assert.Equal(t, 899.0, microdata.Fields["price"])
+	assert.Equal(t, "OutOfStock", microdata.Fields["availability"])
+
+	rdfa, err := crawler.ParseDocument("https://example.com/iphone", strings.NewReader(rdfaPage))
+	assert.NoError(t, err)
+	assert.Equal(t, "iPhone", rdfa.Fields["name"])
+	assert.Equal(t, 1099.0, rdfa.Fields["price"])
+
+	// Index the documents and search on their fields
+	db, err := storage.OpenBolt(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	p := pipeline.NewPipeline(indexer.NewIndexer(db, nil))
+	for _, doc := range []*crawler.Document{jsonLD, microdata, rdfa} {
+		assert.NoError(t, p.Add(doc))
+	}
+	assert.NoError(t, p.Flush())
+
+	s := search.NewSearcher(db)
+	results, err := s.Search("phone", &search.SearchOptions{SortBy: "-price"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/iphone", "https://example.com/galaxy", "https://example.com/nokia"}, results)
+
+	results, err = s.Search("phone", &search.SearchOptions{SortBy: "price", Filters: map[string]string{"availability": "InStock"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/nokia", "https://example.com/iphone"}, results)
+}
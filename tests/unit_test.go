@@ -7,6 +7,7 @@ import (
 
 	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
 	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -47,12 +48,12 @@ func TestIndexerIndex(t *testing.T) {
 	}()
 
 	t.Log("Creating Redis client...")
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379", // Replace with your Redis server address
-	})
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer redisClient.Close()
 
 	t.Log("Creating indexer...")
-	idx := indexer.NewIndexer(db.DB, redisClient)
+	idx := indexer.NewIndexer(db.DB, indexer.NewRedisCache(redisClient))
 
 	t.Log("Preparing test data...")
 
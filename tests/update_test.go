@@ -0,0 +1,109 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/crawler"
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/pipeline"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUpdateAndDeleteDocuments tests that updates and deletions remove stale postings.
+func TestUpdateAndDeleteDocuments(t *testing.T) {
+	db, err := storage.OpenBolt(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, nil)
+	s := search.NewSearcher(db)
+	err = idx.IndexDocuments([]indexer.Document{
+		{URL: URL1, Title: "Phones", Body: "cheap tablets"},
+		{URL: URL2, Title: "Phones", Body: "laptops"},
+	})
+	assert.NoError(t, err)
+
+	// Unchanged documents are not re-indexed
+	changed, err := idx.UpdateDocument(indexer.Document{URL: URL1, Title: "Phones", Body: "cheap tablets"})
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	changed, err = idx.UpdateDocument(indexer.Document{URL: URL1, Title: "Phones", Body: "cheap laptops"})
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	results, err := s.Search("tablets", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+	results, err = s.Search("laptops", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{URL1, URL2}, results)
+
+	deleted, err := idx.DeleteDocument(URL2)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = idx.DeleteDocumentByID(42)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	results, err = s.Search("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL1}, results)
+	doc, err := idx.Documents().GetByURL(URL2)
+	assert.NoError(t, err)
+	assert.Nil(t, doc)
+
+	// Cleanup purges the tombstoned postings: "tablets" of URL1 and "phones", "laptops" of URL2
+	removed, err := idx.Cleanup()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, removed)
+	removed, err = idx.Cleanup()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	results, err = s.Search("laptops", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL1}, results)
+
+	// A deleted URL indexed again gets a new ID
+	assert.NoError(t, idx.IndexDocuments([]indexer.Document{{URL: URL2, Body: "laptops"}}))
+	doc, err = idx.Documents().GetByURL(URL2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), doc.ID)
+}
+
+// TestCrawlerRemovesGonePages tests that the crawler deletes pages answering 404 or 410 from the index.
+func TestCrawlerRemovesGonePages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<html><body>Phones <a href="/gone">gone</a></body></html>`))
+		default:
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer server.Close()
+
+	db, err := storage.OpenBolt(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, nil)
+	assert.NoError(t, idx.IndexDocuments([]indexer.Document{{URL: server.URL + "/gone", Title: "Phones"}}))
+
+	p := pipeline.NewPipeline(idx)
+	c := crawler.NewCrawler(1, 1)
+	c.SetIndex(p)
+	assert.NoError(t, c.Crawl(server.URL+"/", 0))
+	c.Wait()
+	assert.NoError(t, p.Flush())
+
+	results, err := search.NewSearcher(db).Search("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{server.URL + "/"}, results)
+}
@@ -0,0 +1,76 @@
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Mdromi/golang-search-engine/search-engine/indexer"
+	"github.com/Mdromi/golang-search-engine/search-engine/search"
+	"github.com/Mdromi/golang-search-engine/search-engine/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWriterBatches tests that the writer commits a segment for every full batch.
+func TestWriterBatches(t *testing.T) {
+	db, err := storage.OpenBolt(filepath.Join(t.TempDir(), "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, nil)
+	w, err := indexer.NewWriter(idx, indexer.WriterConfig{BatchSize: 2})
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.Add(indexer.Document{URL: URL1, Body: "phones"}))
+	assert.Equal(t, 1, w.Pending())
+	assert.NoError(t, w.Add(indexer.Document{URL: URL2, Body: "phones"}, indexer.Document{URL: URL3, Body: "phones"}))
+	assert.Equal(t, 0, w.Pending())
+
+	results, err := search.NewSearcher(db).Search("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+
+	stats, err := idx.SegmentStats()
+	assert.NoError(t, err)
+	assert.Len(t, stats.Segments, 1)
+}
+
+// TestWriterRecovery tests that acknowledged documents survive a crash through the write-ahead log.
+func TestWriterRecovery(t *testing.T) {
+	dir := t.TempDir()
+	walPath := filepath.Join(dir, "ingest.wal")
+	db, err := storage.OpenBolt(filepath.Join(dir, "index.db"))
+	assert.NoError(t, err)
+	defer db.Close()
+
+	idx := indexer.NewIndexer(db, nil)
+	s := search.NewSearcher(db)
+
+	// Acknowledge documents without committing them, then "crash"
+	w, err := indexer.NewWriter(idx, indexer.WriterConfig{WALPath: walPath})
+	assert.NoError(t, err)
+	assert.NoError(t, w.Add(indexer.Document{URL: URL1, Body: "phones"}, indexer.Document{URL: URL2, Body: "phones"}))
+	assert.NoError(t, w.Delete(URL2))
+
+	results, err := s.Search("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	// A torn record left by the crash is dropped
+	file, err := os.OpenFile(walPath, os.O_APPEND|os.O_WRONLY, 0666)
+	assert.NoError(t, err)
+	file.Write([]byte{0x7f, 1, 2})
+	file.Close()
+
+	recovered, err := indexer.NewWriter(idx, indexer.WriterConfig{WALPath: walPath})
+	assert.NoError(t, err)
+	defer recovered.Close()
+
+	results, err = s.Search("phones", &search.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{URL1}, results)
+
+	info, err := os.Stat(walPath)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+}